@@ -1,64 +1,115 @@
-package main
+package binlog
 
 import (
 	"fmt"
-	"log"
 	"io"
+	"iter"
 	"os"
 )
 
 // Determines the binlog version from the first event
 // http://dev.mysql.com/doc/internals/en/determining-binary-log-version.html
 func determineLogVersion(typeCode byte, length uint32) uint8 {
-	if typeCode != START_EVENT_V3 && typeCode != FORMAT_DESCRIPTION_EVENT {
-		return 3
-	} else if typeCode == START_EVENT_V3 {
+	switch typeCode {
+	case START_EVENT_V3:
 		if length < 75 {
 			return 1
-		} else {
-			return 3
 		}
-	} else if typeCode == FORMAT_DESCRIPTION_EVENT {
+
+		return 3
+
+	case FORMAT_DESCRIPTION_EVENT:
 		return 4
-	} else {
-		log.Fatal(fmt.Sprintf("Could not determine log version from: [%v, %v]", typeCode, length))
-	}
 
-	return 0
+	default:
+		return 3
+	}
 }
 
 type Binlog struct {
 	reader     io.ReadSeeker
+	file       string
 	logVersion uint8
+	err        error
+	logger     Logger
+	tableMaps  TableMapCollection
+
+	formatDescription *FormatDescriptionEvent
+	previousGtids     GTIDSet
+	verifyChecksums   bool
+	schema            Schema
+}
+
+// Configures optional behaviour of a Binlog, passed to OpenBinlog or NewBinlog
+type Option func(*Binlog)
+
+// Sends decoding traces to l instead of discarding them
+func WithLogger(l Logger) Option {
+	return func(b *Binlog) {
+		b.logger = l
+	}
+}
+
+// Records the path the binlog was opened from, so events can report it
+func withFile(path string) Option {
+	return func(b *Binlog) {
+		b.file = path
+	}
 }
 
-func OpenBinlog(filepath string) (*Binlog, error) {
+// Skips checking event CRC32 checksums for speed. The checksums are
+// still stripped off before the events are decoded.
+func WithoutChecksumVerification() Option {
+	return func(b *Binlog) {
+		b.verifyChecksums = false
+	}
+}
+
+// Supplies column signedness for tables whose table maps don't carry it
+// (see TableMapEvent.IsUnsigned)
+func WithSchema(schema Schema) Option {
+	return func(b *Binlog) {
+		b.schema = schema
+	}
+}
+
+// Opens the binlog file at filepath and positions it at the first
+// event after the format description. Close the binlog when done.
+func OpenBinlog(filepath string, options ...Option) (*Binlog, error) {
 	file, err := os.OpenFile(filepath, os.O_RDONLY, 0)
 
 	if err != nil {
 		return nil, err
 	}
 
-	/*
-	fmt.Println("unsigned")
-	for i := 0; i < 19; i++ {
-		b := make([]byte, 1)
-		_, err := file.Read(b)
-		fatalErr(err)
-		fmt.Printf("%v =   %b - %v\n", i, uint8(b[0]), uint8(b[0]))
-	}
+	b, err := NewBinlog(file, append([]Option{withFile(filepath)}, options...)...)
 
-	file.Seek(0, 0)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
 
-	log.Fatal("done")
-	*/
+	return b, nil
+}
 
+// Wraps an already opened binlog stream. The reader must be positioned
+// at the magic bytes at the very start of the log.
+func NewBinlog(file io.ReadSeeker, options ...Option) (*Binlog, error) {
 	b := &Binlog{
 		reader: file,
 		logVersion: 0,
+		logger: nopLogger{},
+		tableMaps: make(TableMapCollection),
+		verifyChecksums: true,
 	}
 
-	b.mustFindLogVersion()
+	for _, option := range options {
+		option(b)
+	}
+
+	if err := b.readFormatDescription(); err != nil {
+		return nil, err
+	}
 
 	return b, nil
 }
@@ -89,37 +140,47 @@ We also take this time to check the magic bytes. Every binlog,
 no matter which version, starts with 4 magic bytes that are always
 0xfe followed by 'b', 'i', and 'n'. This is normally ignored,
 but we check it to make sure this is actually a binlog before
-we try and parse things we shouldn't. This will probably be
-moved soon, along with this message.
+we try and parse things we shouldn't.
+
+Once we know it is a v4 log, the first event is read again in full
+as a FORMAT_DESCRIPTION_EVENT, which describes the header and
+post-header sizes of everything that follows.
 
 */
 
-// Finds log version and move reader to end of first event
-// assumes reader is still at beginning of file
-func (b *Binlog) mustFindLogVersion() {
+// Finds log version and reads the format description event, leaving the
+// reader at the start of the second event. Assumes reader is still at
+// beginning of file.
+func (b *Binlog) readFormatDescription() error {
 	magic, err := ReadBytes(b.reader, 4)
 
 	if err != nil {
-		log.Fatal("Something went wrong when reading magic number:", err)
+		return fmt.Errorf("reading magic number: %v", err)
 	}
 
 	if !checkBinlogMagic(magic) {
-		log.Fatal("Binlog magic number was not correct. This is probably not a binlog.")
+		return ErrBadMagic
 	}
 
 	// Skip timestamp
-	fatalErr(b.Skip(4))
+	if err = b.Skip(4); err != nil {
+		return err
+	}
+
 	typeCode, err := ReadType(b.reader)
 
 	if err != nil {
-		log.Fatal("Failed to read type_byte:", err)
+		return fmt.Errorf("reading type_code: %v", err)
+	}
+
+	if err = b.SetPosition(EVENT_LEN_OFFSET + 4); err != nil {
+		return err
 	}
 
-	fatalErr(b.SetPosition(EVENT_LEN_OFFSET + 4))
 	length, err := ReadLength(b.reader)
 
 	if err != nil {
-		log.Fatal("Failed to read event_length:", err)
+		return fmt.Errorf("reading event_length: %v", err)
 	}
 
 	b.logVersion = determineLogVersion(typeCode, length)
@@ -127,19 +188,42 @@ func (b *Binlog) mustFindLogVersion() {
 	// From here on out, we assume v4 events (for now)
 	// this just errors out if it isn't v4
 	if b.logVersion != 4 {
-		log.Fatal("Sorry, this only supports v4 logs right now.", b.logVersion)
+		return &ErrUnsupportedVersion{Version: b.logVersion}
 	}
 
-	fatalErr(b.SetPosition(EVENT_NEXT_OFFSET + 4))
-	nextPos, err := ReadNextPosition(b.reader)
+	if err = b.SetPosition(int64(len(BINLOG_MAGIC))); err != nil {
+		return err
+	}
 
-	if err != nil {
-		log.Fatal("Failed to read event_length:", err)
+	// The deserializer stores the event on b.formatDescription
+	if _, err = b.readEvent(); err != nil {
+		if err == io.EOF {
+			err = &ErrTruncatedEvent{Offset: int64(len(BINLOG_MAGIC)), EventType: FORMAT_DESCRIPTION_EVENT}
+		}
+
+		return err
 	}
 
-	fatalErr(b.SetPosition(int64(nextPos)))
+	return nil
+}
+
+// Returns the path given to OpenBinlog, or "" for a binlog made with NewBinlog
+func (b *Binlog) File() string {
+	return b.file
+}
+
+// Returns the format description event read from the start of the log
+func (b *Binlog) FormatDescription() *FormatDescriptionEvent {
+	return b.formatDescription
+}
+
+// Closes the underlying reader if it can be closed
+func (b *Binlog) Close() error {
+	if closer, ok := b.reader.(io.Closer); ok {
+		return closer.Close()
+	}
 
-	fmt.Println("Set position to ", nextPos)
+	return nil
 }
 
 func (b *Binlog) SetPosition(n int64) error {
@@ -153,6 +237,65 @@ func (b *Binlog) Skip(n int64) error {
 	return err
 }
 
-func (b *Binlog) NextEvent() *Event {
-	return ReadEvent(b.reader)
+// Reads the next event in the log. Returns io.EOF once the log has been
+// read to the end and *ErrTruncatedEvent if it ends part way through an event.
+func (b *Binlog) NextEvent() (*Event, error) {
+	return b.readEvent()
+}
+
+// Returns an iterator over the remaining events for use with range:
+//
+//	for event := range b.Events() {
+//		...
+//	}
+//	if err := b.Err(); err != nil {
+//		...
+//	}
+//
+// Iteration stops at the end of the log or at the first error, which
+// is then returned by Err.
+func (b *Binlog) Events() iter.Seq[*Event] {
+	return iterate(b.NextEvent, &b.err)
+}
+
+// Turns a NextEvent style function into a range iterator, stopping
+// quietly at io.EOF and storing any other error in err
+func iterate[T any](next func() (T, error), err *error) iter.Seq[T] {
+	return func(yield func(T) bool) {
+		*err = nil
+
+		for {
+			value, nextErr := next()
+
+			if nextErr != nil {
+				if nextErr != io.EOF {
+					*err = nextErr
+				}
+
+				return
+			}
+
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// Returns the GTIDs executed before this file was started, taken from the
+// PREVIOUS_GTIDS_EVENT. That is the second event in the file, so this is
+// nil until it has been read (or if the server does not write it).
+func (b *Binlog) PreviousGtids() GTIDSet {
+	return b.previousGtids
+}
+
+// Returns the table map events seen so far, keyed by table id
+func (b *Binlog) TableMaps() TableMapCollection {
+	return b.tableMaps
+}
+
+// Returns the error that stopped the last Events iteration, or nil if it
+// reached the end of the log
+func (b *Binlog) Err() error {
+	return b.err
 }
@@ -0,0 +1,232 @@
+package binlog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+/*
+MULTIPLE FILES
+==============
+
+The server writes to a numbered series of files (mysql-bin.000001,
+mysql-bin.000002, ...) and lists them, oldest first, in an index file
+(mysql-bin.index). Every file but the one currently being written to
+ends with a ROTATE_EVENT naming the next file.
+
+A BinlogSequence reads through such a series as if it was one log.
+When a file runs out it opens the file named by the last ROTATE_EVENT
+(looked up next to the current file), or the next file in the list if
+there was no rotation, and carries on from there. Every event still
+reports which file it came from with Event.File.
+
+*/
+
+type BinlogSequence struct {
+	files   []string
+	index   int
+	current *Binlog
+	rotate  *RotateEvent
+	options []Option
+	err     error
+}
+
+// Opens the binlogs listed in a mysql-bin.index file. Relative entries
+// (MySQL writes them as ./mysql-bin.000001) are resolved against the
+// directory the index file is in.
+func OpenBinlogIndex(indexPath string, options ...Option) (*BinlogSequence, error) {
+	file, err := os.Open(indexPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	dir := filepath.Dir(indexPath)
+	files := []string{}
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" {
+			continue
+		}
+
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(dir, line)
+		}
+
+		files = append(files, line)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewBinlogSequence(files, options...)
+}
+
+// Opens the binlogs matching a pattern such as /var/lib/mysql/mysql-bin.*
+// in name order, which is the order they were written in. Index files
+// matching the pattern are left out.
+func OpenBinlogGlob(pattern string, options ...Option) (*BinlogSequence, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+
+	for _, match := range matches {
+		if filepath.Ext(match) != ".index" {
+			files = append(files, match)
+		}
+	}
+
+	sort.Strings(files)
+
+	return NewBinlogSequence(files, options...)
+}
+
+// Opens the first of files, which are read in the given order. The
+// options are applied to every file opened.
+func NewBinlogSequence(files []string, options ...Option) (*BinlogSequence, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no binlog files to read")
+	}
+
+	current, err := OpenBinlog(files[0], options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BinlogSequence{
+		files:   files,
+		current: current,
+		options: options,
+	}, nil
+}
+
+// Returns the binlog currently being read
+func (s *BinlogSequence) Current() *Binlog {
+	return s.current
+}
+
+func (s *BinlogSequence) Close() error {
+	return s.current.Close()
+}
+
+// Reads the next event, moving on to the next file when the current one
+// runs out. Returns io.EOF at the end of the last file.
+func (s *BinlogSequence) NextEvent() (*Event, error) {
+	for {
+		event, err := s.current.NextEvent()
+
+		if err == nil {
+			// Relay logs can start with a rotation to themselves
+			if rotate, ok := event.data.(*RotateEvent); ok && rotate.NextFile != filepath.Base(s.current.File()) {
+				s.rotate = rotate
+			}
+
+			return event, nil
+		}
+
+		if err != io.EOF {
+			return nil, err
+		}
+
+		advanced, err := s.advance()
+		if err != nil {
+			return nil, err
+		}
+
+		if !advanced {
+			return nil, io.EOF
+		}
+	}
+}
+
+// Opens the file after the current one, returning false if there is none
+func (s *BinlogSequence) advance() (bool, error) {
+	var next string
+	index := s.index + 1
+	position := int64(0)
+
+	if s.rotate != nil {
+		position = int64(s.rotate.Position)
+		next = ""
+
+		for i := s.index + 1; i < len(s.files); i++ {
+			if filepath.Base(s.files[i]) == s.rotate.NextFile {
+				next = s.files[i]
+				index = i
+				break
+			}
+		}
+
+		// A rotation to a file created after the sequence was opened
+		if next == "" {
+			next = filepath.Join(filepath.Dir(s.current.File()), s.rotate.NextFile)
+
+			if _, err := os.Stat(next); os.IsNotExist(err) {
+				return false, nil
+			} else if err != nil {
+				return false, err
+			}
+
+			s.files = append(s.files[:s.index + 1], next)
+		}
+	} else if index < len(s.files) {
+		next = s.files[index]
+	} else {
+		return false, nil
+	}
+
+	b, err := OpenBinlog(next, s.options...)
+	if err != nil {
+		return false, err
+	}
+
+	// The format description has been read, only skip further if the
+	// rotation asked to start past it
+	if current, err := b.reader.Seek(0, 1); err == nil && position > current {
+		if err = b.SetPosition(position); err != nil {
+			b.Close()
+			return false, err
+		}
+	}
+
+	s.current.Close()
+
+	s.current = b
+	s.index = index
+	s.rotate = nil
+
+	return true, nil
+}
+
+// Works the same as Binlog.NextTransaction, across files
+func (s *BinlogSequence) NextTransaction() (*Transaction, error) {
+	return readTransaction(s.NextEvent)
+}
+
+// Works the same as Binlog.Events, across files
+func (s *BinlogSequence) Events() iter.Seq[*Event] {
+	return iterate(s.NextEvent, &s.err)
+}
+
+// Works the same as Binlog.Transactions, across files
+func (s *BinlogSequence) Transactions() iter.Seq[*Transaction] {
+	return iterate(s.NextTransaction, &s.err)
+}
+
+// Returns the error that stopped the last Events or Transactions iteration
+func (s *BinlogSequence) Err() error {
+	return s.err
+}
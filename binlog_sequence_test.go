@@ -0,0 +1,103 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rotateTestEvent(nextFile string) testEvent {
+	payload := new(bytes.Buffer)
+	binary.Write(payload, binary.LittleEndian, uint64(4))
+	payload.WriteString(nextFile)
+
+	return testEvent{ROTATE_EVENT, payload.Bytes()}
+}
+
+func writeTestBinlogs(t *testing.T, files map[string][]byte) string {
+	dir := t.TempDir()
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+type sequencePosition struct {
+	file string
+	kind byte
+}
+
+func readSequence(t *testing.T, s *BinlogSequence) []sequencePosition {
+	positions := []sequencePosition{}
+
+	for event := range s.Events() {
+		positions = append(positions, sequencePosition{filepath.Base(event.File()), event.Header().Type})
+	}
+
+	checkErr(t, s.Err())
+	checkErr(t, s.Close())
+
+	return positions
+}
+
+func TestBinlogSequenceIndex(t *testing.T) {
+	dir := writeTestBinlogs(t, map[string][]byte{
+		"mysql-bin.000001": makeTestBinlog(queryTestEvent("", "CREATE TABLE a (id INT)", nil), rotateTestEvent("mysql-bin.000002")),
+		"mysql-bin.000002": makeChecksummedTestBinlog(testEvent{STOP_EVENT, nil}),
+		"mysql-bin.000003": makeTestBinlog(queryTestEvent("", "CREATE TABLE b (id INT)", nil)),
+		"mysql-bin.index":  []byte("./mysql-bin.000001\n./mysql-bin.000002\n./mysql-bin.000003\n"),
+	})
+
+	s, err := OpenBinlogIndex(filepath.Join(dir, "mysql-bin.index"))
+	checkErr(t, err)
+
+	assert.Equal(t, []sequencePosition{
+		{"mysql-bin.000001", QUERY_EVENT},
+		{"mysql-bin.000001", ROTATE_EVENT},
+		{"mysql-bin.000002", STOP_EVENT},
+		{"mysql-bin.000003", QUERY_EVENT},
+	}, readSequence(t, s))
+}
+
+func TestBinlogSequenceFollowsRotation(t *testing.T) {
+	dir := writeTestBinlogs(t, map[string][]byte{
+		"mysql-bin.000001": makeTestBinlog(rotateTestEvent("mysql-bin.000003")),
+		"mysql-bin.000002": makeTestBinlog(testEvent{STOP_EVENT, nil}),
+		"mysql-bin.000003": makeTestBinlog(rotateTestEvent("mysql-bin.000004")),
+		"mysql-bin.000004": makeTestBinlog(queryTestEvent("", "DROP TABLE a", nil)),
+		"mysql-bin.index":  []byte("./mysql-bin.000001\n./mysql-bin.000002\n"),
+	})
+
+	s, err := OpenBinlogIndex(filepath.Join(dir, "mysql-bin.index"))
+	checkErr(t, err)
+
+	assert.Equal(t, []sequencePosition{
+		{"mysql-bin.000001", ROTATE_EVENT},
+		{"mysql-bin.000003", ROTATE_EVENT},
+		{"mysql-bin.000004", QUERY_EVENT},
+	}, readSequence(t, s))
+}
+
+func TestBinlogSequenceGlob(t *testing.T) {
+	dir := writeTestBinlogs(t, map[string][]byte{
+		"mysql-bin.000002": makeTestBinlog(queryTestEvent("", "DROP TABLE a", nil)),
+		"mysql-bin.000001": makeTestBinlog(testEvent{STOP_EVENT, nil}),
+		"mysql-bin.index":  []byte("./mysql-bin.000001\n"),
+	})
+
+	s, err := OpenBinlogGlob(filepath.Join(dir, "mysql-bin.*"))
+	checkErr(t, err)
+
+	assert.Equal(t, []sequencePosition{
+		{"mysql-bin.000001", STOP_EVENT},
+		{"mysql-bin.000002", QUERY_EVENT},
+	}, readSequence(t, s))
+}
@@ -0,0 +1,318 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEvent struct {
+	typeCode byte
+	payload  []byte
+}
+
+// Post header lengths written by a MySQL 5.6 server, indexed by type code - 1
+var testPostHeaderLengths = []byte{
+	56, 13, 0, 8, 0, 18, 0, 4, 4, 4, 4, 18, 0, 0, 92, 0, 4, 26, 8, 0,
+	0, 0, 8, 8, 8, 2, 0, 0, 0, 10, 10, 10, 42, 42, 0,
+}
+
+func formatDescriptionTestEvent(checksumAlgorithm byte) testEvent {
+	payload := new(bytes.Buffer)
+
+	binary.Write(payload, binary.LittleEndian, uint16(4))
+
+	serverVersion := make([]byte, 50)
+	copy(serverVersion, "5.6.20-log")
+	payload.Write(serverVersion)
+
+	binary.Write(payload, binary.LittleEndian, uint32(0))
+	payload.WriteByte(EVENT_HEADER_LENGTH)
+	payload.Write(testPostHeaderLengths)
+
+	// checksum algorithm, the checksum itself is filled in with the
+	// rest of the event's footer
+	payload.WriteByte(checksumAlgorithm)
+	if checksumAlgorithm == BINLOG_CHECKSUM_ALG_OFF {
+		payload.Write([]byte{0, 0, 0, 0})
+	}
+
+	return testEvent{FORMAT_DESCRIPTION_EVENT, payload.Bytes()}
+}
+
+// Builds a binlog file image: the magic bytes, a format description
+// event and then the given events, with each header's length and next
+// position filled in.
+func makeTestBinlog(events ...testEvent) []byte {
+	return buildTestBinlog(BINLOG_CHECKSUM_ALG_OFF, events...)
+}
+
+// Same as makeTestBinlog, with a CRC32 footer on every event
+func makeChecksummedTestBinlog(events ...testEvent) []byte {
+	return buildTestBinlog(BINLOG_CHECKSUM_ALG_CRC32, events...)
+}
+
+func buildTestBinlog(checksumAlgorithm byte, events ...testEvent) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(BINLOG_MAGIC[:])
+
+	footer := 0
+	if checksumAlgorithm == BINLOG_CHECKSUM_ALG_CRC32 {
+		footer = BINLOG_CHECKSUM_LENGTH
+	}
+
+	for _, e := range append([]testEvent{formatDescriptionTestEvent(checksumAlgorithm)}, events...) {
+		start := buf.Len()
+		length := uint32(EVENT_HEADER_LENGTH + len(e.payload) + footer)
+		nextPosition := uint32(buf.Len()) + length
+
+		binary.Write(buf, binary.LittleEndian, uint32(1400000000)) // timestamp
+		buf.WriteByte(e.typeCode)
+		binary.Write(buf, binary.LittleEndian, uint32(1)) // server id
+		binary.Write(buf, binary.LittleEndian, length)
+		binary.Write(buf, binary.LittleEndian, nextPosition)
+		buf.Write([]byte{0, 0}) // flags
+		buf.Write(e.payload)
+
+		if footer > 0 {
+			binary.Write(buf, binary.LittleEndian, crc32.ChecksumIEEE(buf.Bytes()[start:]))
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func openTestBinlog(t *testing.T, data []byte, options ...Option) *Binlog {
+	b, err := NewBinlog(bytes.NewReader(data), options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func TestNewBinlogBadMagic(t *testing.T) {
+	data := makeTestBinlog()
+	data[0] = 0
+
+	_, err := NewBinlog(bytes.NewReader(data))
+	assert.Equal(t, ErrBadMagic, err)
+}
+
+func TestNextEventEOF(t *testing.T) {
+	b := openTestBinlog(t, makeTestBinlog(testEvent{STOP_EVENT, nil}))
+
+	event, err := b.NextEvent()
+	checkErr(t, err)
+	assert.Equal(t, STOP_EVENT, event.Header().Type)
+
+	_, err = b.NextEvent()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestNextEventTruncated(t *testing.T) {
+	data := makeTestBinlog(testEvent{STOP_EVENT, nil}, testEvent{INTVAR_EVENT, make([]byte, 9)})
+
+	for _, cut := range []int{3, 12, 25} {
+		b := openTestBinlog(t, data[:len(data) - cut])
+
+		_, err := b.NextEvent()
+		checkErr(t, err)
+
+		_, err = b.NextEvent()
+
+		var truncated *ErrTruncatedEvent
+		if assert.True(t, errors.As(err, &truncated), "expected truncated event error, got %v", err) {
+			assert.Equal(t, int64(len(data) - 28), truncated.Offset)
+		}
+	}
+}
+
+// A log the server is still writing to: data can be appended to it
+type growingTestLog struct {
+	data   []byte
+	offset int64
+}
+
+func (l *growingTestLog) Read(p []byte) (int, error) {
+	if l.offset >= int64(len(l.data)) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, l.data[l.offset:])
+	l.offset += int64(n)
+	return n, nil
+}
+
+func (l *growingTestLog) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += l.offset
+	case io.SeekEnd:
+		offset += int64(len(l.data))
+	}
+
+	l.offset = offset
+	return offset, nil
+}
+
+func TestNextEventTruncatedRetry(t *testing.T) {
+	data := makeTestBinlog(testEvent{STOP_EVENT, nil}, testEvent{INTVAR_EVENT, make([]byte, 9)})
+
+	// Cut in the header and in the payload of the last event
+	for _, cut := range []int{12, 25} {
+		log := &growingTestLog{data: data[:len(data) - cut]}
+
+		b, err := NewBinlog(log)
+		checkErr(t, err)
+
+		_, err = b.NextEvent()
+		checkErr(t, err)
+
+		_, err = b.NextEvent()
+
+		var truncated *ErrTruncatedEvent
+		assert.True(t, errors.As(err, &truncated), "expected truncated event error, got %v", err)
+
+		// Once the server has written the rest the event is read whole
+		log.data = data
+
+		event, err := b.NextEvent()
+		checkErr(t, err)
+		assert.Equal(t, INTVAR_EVENT, event.Header().Type)
+
+		_, err = b.NextEvent()
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestNextEventCorruptLength(t *testing.T) {
+	data := makeTestBinlog(testEvent{STOP_EVENT, nil})
+
+	// A length far beyond the end of the file is a truncated event, found
+	// without allocating the whole length first
+	binary.LittleEndian.PutUint32(data[len(data) - 19 + 9:], 0xffffffff)
+
+	b := openTestBinlog(t, data)
+
+	_, err := b.NextEvent()
+
+	var truncated *ErrTruncatedEvent
+	assert.True(t, errors.As(err, &truncated), "expected truncated event error, got %v", err)
+}
+
+func TestEvents(t *testing.T) {
+	b := openTestBinlog(t, makeTestBinlog(
+		testEvent{STOP_EVENT, nil},
+		testEvent{INTVAR_EVENT, make([]byte, 9)},
+		testEvent{STOP_EVENT, nil},
+	))
+
+	types := []byte{}
+	for event := range b.Events() {
+		types = append(types, event.Header().Type)
+	}
+
+	checkErr(t, b.Err())
+	assert.Equal(t, []byte{STOP_EVENT, INTVAR_EVENT, STOP_EVENT}, types)
+}
+
+func TestWithLogger(t *testing.T) {
+	out := new(bytes.Buffer)
+	data := makeTestBinlog(testEvent{STOP_EVENT, nil})
+
+	b, err := NewBinlog(bytes.NewReader(data), WithLogger(NewWriterLogger(out, LOG_LEVEL_DEBUG)))
+	checkErr(t, err)
+
+	_, err = b.NextEvent()
+	checkErr(t, err)
+
+	assert.Contains(t, out.String(), "DEBUG Event offset=120 type=3 ")
+
+	out.Reset()
+	b, err = NewBinlog(bytes.NewReader(data), WithLogger(NewWriterLogger(out, LOG_LEVEL_INFO)))
+	checkErr(t, err)
+
+	_, err = b.NextEvent()
+	checkErr(t, err)
+
+	assert.Empty(t, out.String())
+}
+
+func TestFormatDescription(t *testing.T) {
+	b := openTestBinlog(t, makeTestBinlog())
+	fde := b.FormatDescription()
+
+	assert.Equal(t, uint16(4), fde.BinlogVersion)
+	assert.Equal(t, "5.6.20-log", fde.ServerVersion)
+	assert.Equal(t, uint8(EVENT_HEADER_LENGTH), fde.HeaderLength)
+	assert.Equal(t, testPostHeaderLengths, fde.PostHeaderLengths)
+	assert.Equal(t, BINLOG_CHECKSUM_ALG_OFF, fde.ChecksumAlgorithm)
+	assert.Equal(t, uint8(8), fde.PostHeaderLength(TABLE_MAP_EVENT))
+	assert.Equal(t, uint8(0), fde.PostHeaderLength(255))
+
+	_, err := b.NextEvent()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestServerSupportsChecksums(t *testing.T) {
+	assert.False(t, serverSupportsChecksums("5.5.40-log"))
+	assert.False(t, serverSupportsChecksums("5.6.0"))
+	assert.True(t, serverSupportsChecksums("5.6.1-m5-log"))
+	assert.True(t, serverSupportsChecksums("5.7.22"))
+	assert.True(t, serverSupportsChecksums("8.0.33"))
+	assert.True(t, serverSupportsChecksums("5.5.40-MariaDB-log"))
+}
+
+func TestChecksums(t *testing.T) {
+	data := makeChecksummedTestBinlog(testEvent{STOP_EVENT, nil}, testEvent{INTVAR_EVENT, make([]byte, 9)})
+
+	b := openTestBinlog(t, data)
+	assert.Equal(t, BINLOG_CHECKSUM_ALG_CRC32, b.FormatDescription().ChecksumAlgorithm)
+	assert.Equal(t, testPostHeaderLengths, b.FormatDescription().PostHeaderLengths)
+
+	count := 0
+	for range b.Events() {
+		count++
+	}
+
+	checkErr(t, b.Err())
+	assert.Equal(t, 2, count)
+
+	// Flip a bit in the INTVAR_EVENT payload
+	corrupt := append([]byte{}, data...)
+	corrupt[len(corrupt) - 6] ^= 0x01
+
+	b = openTestBinlog(t, corrupt)
+
+	_, err := b.NextEvent()
+	checkErr(t, err)
+
+	_, err = b.NextEvent()
+
+	var mismatch *ErrChecksumMismatch
+	if assert.True(t, errors.As(err, &mismatch), "expected checksum mismatch, got %v", err) {
+		assert.Equal(t, int64(len(data) - 32), mismatch.Offset)
+		assert.Equal(t, INTVAR_EVENT, mismatch.EventType)
+	}
+
+	b, err = NewBinlog(bytes.NewReader(corrupt), WithoutChecksumVerification())
+	checkErr(t, err)
+
+	for range b.Events() {
+	}
+
+	checkErr(t, b.Err())
+
+	// The format description checks its own footer
+	corrupt = append([]byte{}, data...)
+	corrupt[30] ^= 0x01
+
+	_, err = NewBinlog(bytes.NewReader(corrupt))
+	assert.True(t, errors.As(err, &mismatch), "expected checksum mismatch, got %v", err)
+}
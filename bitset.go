@@ -1,11 +1,11 @@
-package main
+package binlog
 
 // Based from https://gist.github.com/willf/965762
 // For info on basic bitwise operations: http://stackoverflow.com/a/47990/3830940
 
 import (
+	"fmt"
 	"math"
-	"log"
 )
 
 // Keeping the uint64 from the original for now
@@ -23,9 +23,9 @@ func MakeBitset(maxSize uint) Bitset {
 	return s
 }
 
-func MakeBitsetFromByteArray(bytes []byte, maxSize uint) Bitset {
+func MakeBitsetFromByteArray(bytes []byte, maxSize uint) (Bitset, error) {
 	if int((maxSize + 7) / 8) > len(bytes) {
-		log.Fatal("Bitset maxSize and []byte length mismatch")
+		return nil, fmt.Errorf("bitset maxSize and []byte length mismatch: %v bits from %v bytes", maxSize, len(bytes))
 	}
 
 	bitset := MakeBitset(maxSize)
@@ -40,7 +40,7 @@ func MakeBitsetFromByteArray(bytes []byte, maxSize uint) Bitset {
 		}
 	}
 
-	return bitset
+	return bitset, nil
 }
 
 func (set Bitset) Bit(i uint) bool {
@@ -61,13 +61,14 @@ func (set Bitset) Clear() {
 	}
 }
 
+// Returns bits [start, end) as a new set; an empty range gives an empty set
 func (set Bitset) Splice(start, end uint) Bitset {
-	maxSize := end - start
-
-	if maxSize <= 0 {
-		log.Fatal("Bad start/end values for bitset splicing")
+	if end <= start {
+		return MakeBitset(0)
 	}
 
+	maxSize := end - start
+
 	splicedSet := MakeBitset(maxSize)
 
 	for i := uint(0); i < uint(maxSize); i++ {
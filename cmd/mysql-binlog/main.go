@@ -0,0 +1,87 @@
+// Command mysql-binlog prints the events contained in a MySQL binary log.
+// Given an index file it reads every log listed in it, following rotations.
+//
+//	mysql-binlog [-limit n] [-debug] [-skip-checksums] /path/to/mysql-bin.000001
+//	mysql-binlog [flags] /path/to/mysql-bin.index
+package main
+
+import (
+	"flag"
+	"fmt"
+	"iter"
+	"os"
+	"path/filepath"
+
+	binlog "github.com/lostz/mysql-binlog-go"
+)
+
+// Satisfied by both *binlog.Binlog and *binlog.BinlogSequence
+type eventReader interface {
+	Events() iter.Seq[*binlog.Event]
+	Err() error
+	Close() error
+}
+
+func open(path string, options []binlog.Option) (eventReader, error) {
+	if filepath.Ext(path) == ".index" {
+		return binlog.OpenBinlogIndex(path, options...)
+	}
+
+	return binlog.OpenBinlog(path, options...)
+}
+
+func main() {
+	limit := flag.Int("limit", 0, "stop after reading this many events (0 reads them all)")
+	debug := flag.Bool("debug", false, "write decoding traces to stderr")
+	skipChecksums := flag.Bool("skip-checksums", false, "do not verify event CRC32 checksums")
+
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] <binlog file or .index file>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	options := []binlog.Option{}
+
+	if *debug {
+		options = append(options, binlog.WithLogger(binlog.NewWriterLogger(os.Stderr, binlog.LOG_LEVEL_DEBUG)))
+	}
+
+	if *skipChecksums {
+		options = append(options, binlog.WithoutChecksumVerification())
+	}
+
+	reader, err := open(flag.Arg(0), options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	defer reader.Close()
+
+	count := 0
+
+	for event := range reader.Events() {
+		count++
+		header := event.Header()
+
+		fmt.Printf("#%v %v:%v type=%v server_id=%v length=%v next_pos=%v\n",
+			count, filepath.Base(event.File()), event.Offset(),
+			header.Type, header.ServerId, header.Length, header.NextPosition)
+		fmt.Printf("  %+v\n", event.Data())
+
+		if *limit > 0 && count >= *limit {
+			break
+		}
+	}
+
+	if err := reader.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
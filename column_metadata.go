@@ -1,10 +1,9 @@
-package main
+package binlog
 
 import (
 	"bytes"
-	"io"
-	"log"
 	"fmt"
+	"io"
 )
 
 type MetadataType byte
@@ -28,12 +27,26 @@ VARCHAR_METADATA
 STRING_METADATA
 [2]byte: [byte realtype (mysql var type), uint8 packsize]
 
+For CHAR columns longer than 255 bytes the length needs 10 bits. The
+two extra bits are stored inverted in bits 4 and 5 of the real type,
+which are always set for the real types that can appear here:
+if realtype & 0x30 != 0x30 {
+	length   = packsize | (((realtype & 0x30) ^ 0x30) << 4)
+	realtype = realtype | 0x30
+}
+For ENUM the length is the size of the index (1 or 2 bytes), for SET
+the size of the bitmask (1 to 8 bytes).
+
 BITSET_METADATA
 [2]byte: [uint8 number of bits, uint8 packsize]
+BIT(n) is stored as n / 8 whole bytes (packsize) and n % 8 bits
 
 NEW_DECIMAL_METADATA
 [2]byte: [uint8 precision, uint8 number of decimals]
 
+TIME_V2_METADATA
+[1]byte: [uint8 fractional seconds precision (0 to 6)]
+
 */
 
 const (
@@ -45,8 +58,31 @@ const (
 	TIME_V2_METADATA
 )
 
-func fatalMetadataLengthMismatch() {
-	log.Fatal("Mismatch of metadata length!")
+func metadataLengthMismatch(m *ColumnMetadata, expected int) error {
+	return fmt.Errorf("mismatch of metadata length: expected %v bytes, got %v", expected, len(m.data))
+}
+
+func metadataTypeMismatch(method string, expected MetadataType) error {
+	return fmt.Errorf("cannot call %v() on metadata that is not %v", method, expected)
+}
+
+func (t MetadataType) String() string {
+	switch t {
+	case PACK_SIZE_METADATA:
+		return "PACK_SIZE_METADATA"
+	case VARCHAR_METADATA:
+		return "VARCHAR_METADATA"
+	case STRING_METADATA:
+		return "STRING_METADATA"
+	case BITSET_METADATA:
+		return "BITSET_METADATA"
+	case NEW_DECIMAL_METADATA:
+		return "NEW_DECIMAL_METADATA"
+	case TIME_V2_METADATA:
+		return "TIME_V2_METADATA"
+	}
+
+	return fmt.Sprintf("MetadataType(%d)", byte(t))
 }
 
 type ColumnMetadata struct {
@@ -54,32 +90,38 @@ type ColumnMetadata struct {
 	metaType MetadataType
 }
 
-func DeserializeColomnMetadata(r io.Reader, colType byte) *ColumnMetadata {
+func DeserializeColomnMetadata(r io.Reader, colType byte) (*ColumnMetadata, error) {
 	switch colType {
 
 	// 1 byte pack size cases
-	case MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE, MYSQL_TYPE_BLOB, MYSQL_TYPE_GEOMETRY:
+	case MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE, MYSQL_TYPE_BLOB, MYSQL_TYPE_GEOMETRY, MYSQL_TYPE_JSON:
 		data, err := ReadBytes(r, 1)
-		fatalErr(err)
+		if err != nil {
+			return nil, err
+		}
 
 		return &ColumnMetadata{
 			data: data,
 			metaType: PACK_SIZE_METADATA,
-		}
+		}, nil
 	
 	case MYSQL_TYPE_TIMESTAMP_V2, MYSQL_TYPE_TIME_V2, MYSQL_TYPE_DATETIME_V2:
 		data, err := ReadBytes(r, 1)
-		fatalErr(err)
+		if err != nil {
+			return nil, err
+		}
 
 		return &ColumnMetadata{
 			data: data,
 			metaType: TIME_V2_METADATA,
-		}
+		}, nil
 
 	// 2 byte cases
 	case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_BIT, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_STRING:
 		data, err := ReadBytes(r, 2)
-		fatalErr(err)
+		if err != nil {
+			return nil, err
+		}
 
 		var metaType MetadataType
 
@@ -100,124 +142,141 @@ func DeserializeColomnMetadata(r io.Reader, colType byte) *ColumnMetadata {
 		return &ColumnMetadata{
 			data: data,
 			metaType: metaType,
-		}
+		}, nil
 	}
 
-	return nil
+	return nil, nil
 }
 
-func (m *ColumnMetadata) PackSize() uint8 {
+func (m *ColumnMetadata) PackSize() (uint8, error) {
 	var toRead []byte
 
 	switch(m.metaType) {
 	case PACK_SIZE_METADATA:
 		if len(m.data) != 1 {
-			fatalMetadataLengthMismatch()
+			return 0, metadataLengthMismatch(m, 1)
 		}
 
 		toRead = m.data[:]
 
 	case STRING_METADATA, BITSET_METADATA: // NOTE: may be big endian (see shyiko version)
-		fmt.Println("!!! HEY, I JUST DECODED STRING METADATA. IF THERE IS AN ERROR BELOW, THIS COULD BE WHY.")
 		if len(m.data) != 2 {
-			fatalMetadataLengthMismatch()
+			return 0, metadataLengthMismatch(m, 2)
 		}
 
 		toRead = m.data[1:]
 
-	case NEW_DECIMAL_METADATA:
-		log.Fatal("Cannot call PackSize() on NEW_DECIMAL_METADATA")
-
-	case VARCHAR_METADATA:
-		log.Fatal("Cannot call PackSize() on VARCHAR_METADATA")
-
 	default:
-		log.Fatal("Invalid metadata type!")
+		return 0, fmt.Errorf("cannot call PackSize() on %v", m.metaType)
 	}
 
-	v, err := uint8FromBuffer(bytes.NewBuffer(toRead))
-	fatalErr(err)
-	return v
+	return uint8FromBuffer(bytes.NewBuffer(toRead))
 }
 
-func (m *ColumnMetadata) RealType() byte {
+// The type of a STRING or VAR_STRING column: STRING, VAR_STRING, ENUM
+// or SET
+func (m *ColumnMetadata) RealType() (byte, error) {
+	realType, _, err := m.RealTypeAndLength()
+	return realType, err
+}
+
+// The real type and the length in bytes of a STRING or VAR_STRING
+// column, see STRING_METADATA
+func (m *ColumnMetadata) RealTypeAndLength() (byte, uint16, error) {
 	if m.metaType != STRING_METADATA {
-		log.Fatal("Cannot call RealType() on metadata that is not STRING_METADATA")
+		return 0, 0, metadataTypeMismatch("RealTypeAndLength", STRING_METADATA)
 	}
 
 	if len(m.data) != 2 {
-		fatalMetadataLengthMismatch()
+		return 0, 0, metadataLengthMismatch(m, 2)
+	}
+
+	realType := m.data[0]
+	length := uint16(m.data[1])
+
+	if realType & 0x30 != 0x30 {
+		length |= uint16((realType & 0x30) ^ 0x30) << 4
+		realType |= 0x30
 	}
 
-	return m.data[0]
+	return realType, length, nil
 }
 
-func (m *ColumnMetadata) MaxLength() uint16 {
+func (m *ColumnMetadata) MaxLength() (uint16, error) {
 	if m.metaType != VARCHAR_METADATA {
-		log.Fatal("Cannot call MaxLength() on metadata that is not VARCHAR_METADATA")
+		return 0, metadataTypeMismatch("MaxLength", VARCHAR_METADATA)
 	}
 
 	if len(m.data) != 2 {
-		fatalMetadataLengthMismatch()
+		return 0, metadataLengthMismatch(m, 2)
 	}
 
-	v, err := uint16FromBuffer(bytes.NewBuffer(m.data))
-	fatalErr(err)
-	return v
+	return uint16FromBuffer(bytes.NewBuffer(m.data))
 }
 
-func (m *ColumnMetadata) Precision() uint8 {
+func (m *ColumnMetadata) Precision() (uint8, error) {
 	if m.metaType != NEW_DECIMAL_METADATA {
-		log.Fatal("Cannot call Precision() on metadata that is not NEW_DECIMAL_METADATA")
+		return 0, metadataTypeMismatch("Precision", NEW_DECIMAL_METADATA)
 	}
 
 	if len(m.data) != 2 {
-		fatalMetadataLengthMismatch()
+		return 0, metadataLengthMismatch(m, 2)
 	}
 
-	v, err := uint8FromBuffer(bytes.NewBuffer(m.data[:1]))
-	fatalErr(err)
-	return v
+	return uint8FromBuffer(bytes.NewBuffer(m.data[:1]))
 }
 
-func (m *ColumnMetadata) Decimals() uint8 {
+func (m *ColumnMetadata) Decimals() (uint8, error) {
 	if m.metaType != NEW_DECIMAL_METADATA {
-		log.Fatal("Cannot call Decimals() on metadata that is not NEW_DECIMAL_METADATA")
+		return 0, metadataTypeMismatch("Decimals", NEW_DECIMAL_METADATA)
 	}
 
 	if len(m.data) != 2 {
-		fatalMetadataLengthMismatch()
+		return 0, metadataLengthMismatch(m, 2)
 	}
 
-	v, err := uint8FromBuffer(bytes.NewBuffer(m.data[1:]))
-	fatalErr(err)
-	return v
+	return uint8FromBuffer(bytes.NewBuffer(m.data[1:]))
 }
 
-func (m *ColumnMetadata) BitsetLength() uint8 {
+func (m *ColumnMetadata) BitsetLength() (uint8, error) {
 	if m.metaType != BITSET_METADATA {
-		log.Fatal("Cannot call BitsetLength() on metadata that is not BITSET_METADATA")
+		return 0, metadataTypeMismatch("BitsetLength", BITSET_METADATA)
 	}
 
 	if len(m.data) != 2 {
-		fatalMetadataLengthMismatch()
+		return 0, metadataLengthMismatch(m, 2)
 	}
 
-	v, err := uint8FromBuffer(bytes.NewBuffer(m.data[:1]))
-	fatalErr(err)
-	return v
+	return uint8FromBuffer(bytes.NewBuffer(m.data[:1]))
 }
 
-func (m *ColumnMetadata) FractionalSecondsPrecision() uint8 {
+// The n of a BIT(n) column, from its whole bytes and leftover bits
+func (m *ColumnMetadata) BitCount() (int, error) {
+	bits, err := m.BitsetLength()
+	if err != nil {
+		return 0, err
+	}
+
+	wholeBytes, err := m.PackSize()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(wholeBytes) * 8 + int(bits), nil
+}
+
+func (m *ColumnMetadata) FractionalSecondsPrecision() (uint8, error) {
 	if m.metaType != TIME_V2_METADATA {
-		log.Fatal("Cannot call FractionalSecondsPrecision() on metadata that is not TIME_V2_METADATA")
+		return 0, metadataTypeMismatch("FractionalSecondsPrecision", TIME_V2_METADATA)
 	}
 
-	if len(m.data) != 2 {
-		fatalMetadataLengthMismatch()
+	if len(m.data) != 1 {
+		return 0, metadataLengthMismatch(m, 1)
+	}
+
+	if m.data[0] > 6 {
+		return 0, fmt.Errorf("fractional seconds precision %v is over 6", m.data[0])
 	}
 
-	v, err := uint8FromBuffer(bytes.NewBuffer(m.data))
-	fatalErr(err)
-	return v
+	return m.data[0], nil
 }
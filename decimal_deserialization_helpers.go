@@ -0,0 +1,143 @@
+package binlog
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// Decimal digits stored in each full 4 byte group
+const DIGITS_PER_INTEGER = 9
+
+// Bytes needed for a group of n < DIGITS_PER_INTEGER leftover digits
+var decimalLeftoverBytes = [DIGITS_PER_INTEGER]int{0, 1, 1, 2, 2, 3, 3, 4, 4}
+
+const (
+	NEWDECIMAL_MAX_PRECISION = 65
+	NEWDECIMAL_MAX_SCALE     = 30
+)
+
+/*
+NEWDECIMAL FORMAT
+=================
+
+Let:
+P = precision (total number of digits), D = decimals (digits after the point)
+I = P - D digits before the point
+
+The digits on each side of the point are split into groups of
+DIGITS_PER_INTEGER, each stored as a big endian integer in 4 bytes. Any
+leftover digits take 1 to 4 bytes (see decimalLeftoverBytes), and sit
+furthest from the point: before the full groups for the integer part,
+after them for the fraction.
+
+(I % 9) digits      = 0-4 bytes
+(I / 9) * 9 digits  = (I / 9) * 4 bytes
+(D / 9) * 9 digits  = (D / 9) * 4 bytes
+(D % 9) digits      = 0-4 bytes
+
+The most significant bit of the first byte is flipped, making it 1 for
+positive numbers. Negative numbers then have every byte inverted.
+
+So with P = 14, D = 4, 1234567890.1234 is
+0x81 0x0D 0xFB 0x38 0xD2 0x04 0xD2
+
+*/
+
+// Reads a NEWDECIMAL as its unscaled value: 1234567890.1234 with 4
+// decimals is 12345678901234
+func ReadNewDecimal(r io.Reader, metadata *ColumnMetadata) (*big.Int, int, error) {
+	precision, err := metadata.Precision()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	decimals, err := metadata.Decimals()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if precision == 0 || precision > NEWDECIMAL_MAX_PRECISION || decimals > NEWDECIMAL_MAX_SCALE || decimals > precision {
+		return nil, 0, fmt.Errorf("invalid NEWDECIMAL precision %v and decimals %v", precision, decimals)
+	}
+
+	integerDigits := int(precision - decimals)
+	fractionDigits := int(decimals)
+
+	// Group sizes in bytes, in the order they are stored
+	groups := []int{decimalLeftoverBytes[integerDigits % DIGITS_PER_INTEGER]}
+	for i := 0; i < integerDigits / DIGITS_PER_INTEGER; i++ {
+		groups = append(groups, 4)
+	}
+	for i := 0; i < fractionDigits / DIGITS_PER_INTEGER; i++ {
+		groups = append(groups, 4)
+	}
+	groups = append(groups, decimalLeftoverBytes[fractionDigits % DIGITS_PER_INTEGER])
+
+	size := 0
+	for _, g := range groups {
+		size += g
+	}
+
+	b, err := ReadBytes(r, size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	negative := b[0] & 0x80 == 0
+	b[0] ^= 0x80
+
+	if negative {
+		for i := range b {
+			b[i] ^= 0xff
+		}
+	}
+
+	// Digits in each group, to zero pad them: the leftover integer digits
+	// need no padding, everything after them does
+	digits := strings.Builder{}
+	offset := 0
+
+	for i, g := range groups {
+		value := uint64(0)
+		for _, c := range b[offset:offset + g] {
+			value = value << 8 | uint64(c)
+		}
+		offset += g
+
+		width := DIGITS_PER_INTEGER
+		switch {
+		case i == 0:
+			width = integerDigits % DIGITS_PER_INTEGER
+		case i == len(groups) - 1:
+			width = fractionDigits % DIGITS_PER_INTEGER
+		}
+
+		if width == 0 {
+			if value != 0 {
+				return nil, 0, fmt.Errorf("invalid NEWDECIMAL: nonzero empty digit group")
+			}
+
+			continue
+		}
+
+		group := fmt.Sprintf("%0*d", width, value)
+		if len(group) > width {
+			return nil, 0, fmt.Errorf("invalid NEWDECIMAL: digit group %v is wider than %v digits", value, width)
+		}
+
+		digits.WriteString(group)
+	}
+
+	unscaled, ok := new(big.Int).SetString("0" + digits.String(), 10)
+	if !ok {
+		return nil, 0, fmt.Errorf("invalid NEWDECIMAL digits %q", digits.String())
+	}
+
+	if negative {
+		unscaled.Neg(unscaled)
+	}
+
+	return unscaled, fractionDigits, nil
+}
@@ -1,11 +1,10 @@
-package main
+package binlog
 
 import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
 	"io"
-	"log"
 )
 
 // These constants may not be necessary later
@@ -96,9 +95,43 @@ func uint8FromBuffer(b *bytes.Buffer) (uint8, error) {
 	return value, err
 }
 
+// Reads above this size from readers that can't tell how much they have
+// left are read in pieces
+const READ_BYTES_CHUNK_SIZE = 64 * 1024
+
+// Reads exactly length bytes. Returns io.EOF if nothing could be read
+// and io.ErrUnexpectedEOF if the reader ran out part way through.
+//
+// Lengths usually come from the log itself, so a corrupt one must not be
+// able to allocate more than the data that is really there. Readers with
+// a Len (like the bytes.Reader every deserializer gets) are checked up
+// front, and large reads from others grow the buffer as the data arrives.
 func ReadBytes(r io.Reader, length int) ([]byte, error) {
+	if length < 0 {
+		return nil, fmt.Errorf("invalid length %v", length)
+	}
+
+	if l, ok := r.(interface{ Len() int }); ok && length > l.Len() {
+		if l.Len() == 0 {
+			return nil, io.EOF
+		}
+
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	if length > READ_BYTES_CHUNK_SIZE {
+		buf := bytes.NewBuffer(make([]byte, 0, READ_BYTES_CHUNK_SIZE))
+
+		n, err := io.CopyN(buf, r, int64(length))
+		if err == io.EOF && n > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return buf.Bytes(), err
+	}
+
 	b := make([]byte, length)
-	n, err := r.Read(b)
+	n, err := io.ReadFull(r, b)
 	return b, checkRead(n, err, b);
 }
 
@@ -155,7 +188,7 @@ func ReadBitset(r io.Reader, bitCount int) (Bitset, error) {
 		return make(Bitset, 0), err
 	}
 
-	return MakeBitsetFromByteArray(b, uint(bitCount)), nil
+	return MakeBitsetFromByteArray(b, uint(bitCount))
 }
 
 func ReadNullTerminatedString(r io.Reader) (string, error) {
@@ -202,16 +235,34 @@ func ReadFlags(r io.Reader) ([]byte, error) {
 	return ReadBytes(r, 2)
 }
 
-func ReadTableId(r io.Reader) (uint64, error) {
-	b, err := ReadBytes(r, 6)
-	fatalErr(err)
+// Table ids are 6 bytes, or 4 in logs written before MySQL 5.1.4
+// (see FormatDescriptionEvent.tableIdLength)
+func ReadTableId(r io.Reader, length int) (uint64, error) {
+	b, err := ReadBytes(r, length)
+	if err != nil {
+		return uint64(0), err
+	}
 
-	// Have to pass 8 byte buffer, so append 6 bytes read to end of 2 '\0' value bytes
-	buf := bytes.NewBuffer(append(b, []byte{NUL, NUL}...))
+	// Have to pass 8 byte buffer, so append the bytes read to the '\0' value bytes
+	buf := bytes.NewBuffer(append(b, make([]byte, 8 - length)...))
 
 	return uint64FromBuffer(buf)
 }
 
+// Reads an unsigned little endian integer of 1 to 8 bytes
+func ReadUintN(r io.Reader, length int) (uint64, error) {
+	if length < 1 || length > 8 {
+		return uint64(0), fmt.Errorf("cannot read a %v byte integer", length)
+	}
+
+	b, err := ReadBytes(r, length)
+	if err != nil {
+		return uint64(0), err
+	}
+
+	return uint64FromBuffer(bytes.NewBuffer(append(b, make([]byte, 8 - length)...)))
+}
+
 /*
 MYSQL PACKED INTEGERS
 =====================
@@ -243,7 +294,9 @@ in an array or anything, it shouldn't cause any issues though.
 
 func ReadPackedInteger(r io.Reader) (uint64, error) {
 	firstByte, err := ReadUint8(r)
-	fatalErr(err)
+	if err != nil {
+		return uint64(0), err
+	}
 
 	if firstByte <= 250 {
 		return uint64(firstByte), nil
@@ -252,27 +305,26 @@ func ReadPackedInteger(r io.Reader) (uint64, error) {
 	bytesToRead := 0
 
 	switch firstByte {
-	case 251:
-		// MySQL error code
-		// something is wrong
-		log.Fatal("Packed integer invalid value:", firstByte)
 	case 252:
 		bytesToRead = 2
 	case 253:
 		bytesToRead = 3
 	case 254:
 		bytesToRead = 8
-	case 255:
-		log.Fatal("Packed integer invalid value:", firstByte)
+	default:
+		// 251 is the MySQL NULL/error marker and 255 is never valid,
+		// something is wrong
+		return uint64(0), fmt.Errorf("packed integer invalid value: %v", firstByte)
 	}
 
-	fmt.Println("Packed Int: Reading", bytesToRead, "more bytes")
-
 	b, err := ReadBytes(r, bytesToRead)
 
 	if err != nil {
 		return uint64(0), err
 	}
 
+	// Pad 2 and 3 byte values out to the 8 bytes uint64FromBuffer expects
+	b = append(b, make([]byte, 8 - bytesToRead)...)
+
 	return uint64FromBuffer(bytes.NewBuffer(b))
 }
@@ -1,8 +1,10 @@
-package main
+package binlog
 
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -50,3 +52,56 @@ func TestReadBitset(t *testing.T) {
 		}
 	}
 }
+
+func TestReadBytes(t *testing.T) {
+	b, err := ReadBytes(bytes.NewReader([]byte{1, 2, 3}), 2)
+	checkErr(t, err)
+	assert.Equal(t, []byte{1, 2}, b)
+
+	_, err = ReadBytes(bytes.NewReader([]byte{1, 2, 3}), -1)
+	assert.Error(t, err)
+
+	_, err = ReadBytes(bytes.NewReader(nil), 1)
+	assert.Equal(t, io.EOF, err)
+
+	_, err = ReadBytes(bytes.NewReader([]byte{1, 2, 3}), math.MaxInt)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	// Readers without a Len are read in pieces rather than trusting the
+	// length with a single allocation
+	data := make([]byte, 3 * READ_BYTES_CHUNK_SIZE)
+	data[len(data) - 1] = 7
+
+	b, err = ReadBytes(io.LimitReader(bytes.NewReader(data), int64(len(data))), len(data))
+	checkErr(t, err)
+	assert.Equal(t, data, b)
+
+	_, err = ReadBytes(io.LimitReader(bytes.NewReader(data), int64(len(data))), 1 << 40)
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = ReadBytes(io.LimitReader(bytes.NewReader(nil), 0), 1 << 40)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadPackedInteger(t *testing.T) {
+	cases := []struct {
+		bytes    []byte
+		expected uint64
+	}{
+		{[]byte{0xfa}, 250},
+		{[]byte{0xfc, 0x01, 0x02}, 0x0201},
+		{[]byte{0xfd, 0x01, 0x02, 0x03}, 0x030201},
+		{[]byte{0xfe, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}, 0x0807060504030201},
+	}
+
+	for _, c := range cases {
+		v, err := ReadPackedInteger(bytes.NewBuffer(c.bytes))
+		checkErr(t, err)
+		assert.Equal(t, c.expected, v)
+	}
+
+	for _, invalid := range []byte{0xfb, 0xff} {
+		_, err := ReadPackedInteger(bytes.NewBuffer([]byte{invalid, 0x00, 0x00}))
+		assert.Error(t, err)
+	}
+}
@@ -0,0 +1,86 @@
+package binlog
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Returned when a file does not start with the binlog magic bytes
+var ErrBadMagic = errors.New("binlog magic number was not correct, this is probably not a binlog")
+
+// Returned when the binlog format version is not one we can parse (only v4 for now)
+type ErrUnsupportedVersion struct {
+	Version uint8
+}
+
+func (e *ErrUnsupportedVersion) Error() string {
+	return fmt.Sprintf("unsupported binlog version v%v (only v4 logs are supported)", e.Version)
+}
+
+// Returned when a column holds a MySQL type that cannot be decoded
+type ErrUnsupportedType struct {
+	MysqlType byte
+}
+
+func (e *ErrUnsupportedType) Error() string {
+	return fmt.Sprintf("unsupported mysql column type: %v", e.MysqlType)
+}
+
+// Returned when a rows event references a table id that no
+// TABLE_MAP_EVENT has described yet
+type ErrMissingTableMap struct {
+	TableId uint64
+}
+
+func (e *ErrMissingTableMap) Error() string {
+	return fmt.Sprintf("never received table map event for table: %v", e.TableId)
+}
+
+// Wraps any failure while decoding a single event, recording where in
+// the file it started and which event type it was. Use errors.As on the
+// returned error to get to the underlying cause.
+type ErrCorruptEvent struct {
+	Offset    int64
+	EventType byte
+	Err       error
+}
+
+func (e *ErrCorruptEvent) Error() string {
+	return fmt.Sprintf("corrupt event (type %v) at offset %v: %v", e.EventType, e.Offset, e.Err)
+}
+
+func (e *ErrCorruptEvent) Unwrap() error {
+	return e.Err
+}
+
+// Returned when the log ends part way through an event, either because the
+// file was cut short or because the server is still writing the event
+type ErrTruncatedEvent struct {
+	Offset    int64
+	EventType byte
+}
+
+func (e *ErrTruncatedEvent) Error() string {
+	return fmt.Sprintf("truncated event (type %v) at offset %v", e.EventType, e.Offset)
+}
+
+// Returned when an event's CRC32 footer does not match its contents
+type ErrChecksumMismatch struct {
+	Offset    int64
+	EventType byte
+	Expected  uint32
+	Actual    uint32
+}
+
+func (e *ErrChecksumMismatch) Error() string {
+	return fmt.Sprintf("checksum mismatch for event (type %v) at offset %v: expected %#08x, got %#08x", e.EventType, e.Offset, e.Expected, e.Actual)
+}
+
+// Returned when the log ends between a BEGIN and its XID/COMMIT
+type ErrIncompleteTransaction struct {
+	StartPosition int64
+}
+
+func (e *ErrIncompleteTransaction) Error() string {
+	return fmt.Sprintf("log ended inside the transaction starting at offset %v", e.StartPosition)
+}
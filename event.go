@@ -1,38 +1,206 @@
-package main
+package binlog
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"io"
 )
 
 type EventData interface {}
 
 type EventDeserializer interface {
-	Deserialize(io.ReadSeeker, *EventHeader) EventData
+	// The Binlog is passed along for state shared between events,
+	// such as the table maps and the logger
+	Deserialize(*Binlog, io.ReadSeeker, *EventHeader) (EventData, error)
 }
 
 type Event struct {
+	file   string
+	offset int64
 	header *EventHeader
 	data   EventData
 }
 
-func ReadEvent(r io.ReadSeeker) *Event {
-	event := new(Event)
+// The binlog file the event was read from (see Binlog.File)
+func (e *Event) File() string {
+	return e.file
+}
+
+// Position of the start of the event in the log
+func (e *Event) Offset() int64 {
+	return e.offset
+}
+
+func (e *Event) Header() *EventHeader {
+	return e.header
+}
+
+// Data holds the deserialized event body, e.g. *RowsEvent or *TableMapEvent
+func (e *Event) Data() EventData {
+	return e.data
+}
+
+/*
+READING EVENTS
+==============
+
+The header is read first, and then the rest of the event (header Length
+minus the header itself) is read into memory in one go. The header
+length comes from the format description event. The deserializers
+only ever see that payload, so a deserializer that reads too little can't
+throw off where the next event starts, and one that reads too much gets an
+error instead of eating into the next event.
+
+When the server writes checksums (binlog_checksum=CRC32, MySQL 5.6.2+),
+every event ends with a 4 byte CRC32 of everything before it. That footer
+is checked and cut off before the payload is handed on, unless the Binlog
+was opened WithoutChecksumVerification, in which case it is only cut off.
+
+If the file ends exactly on an event boundary readEvent returns io.EOF.
+If it ends part way through an event (a log that is still being written
+to, or a file that was cut short) it returns an *ErrTruncatedEvent and
+leaves the reader at the start of that event, so it can be read again
+once the rest of it has been written.
+
+*/
+
+// Rewinds to the start of an event the log ends part way through
+func truncatedEvent(r io.Seeker, offset int64, eventType byte) error {
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	return &ErrTruncatedEvent{Offset: offset, EventType: eventType}
+}
+
+// Reads the event starting at the reader's current position. Any failure
+// is returned as an *ErrCorruptEvent carrying the event offset and type.
+func (b *Binlog) readEvent() (*Event, error) {
+	r := b.reader
+
+	offset, err := r.Seek(0, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &Event{file: b.file, offset: offset}
+
+	headerBytes, err := ReadBytes(r, EVENT_HEADER_LENGTH)
+	switch err {
+	case nil:
+	case io.EOF:
+		return nil, io.EOF
+	case io.ErrUnexpectedEOF:
+		return nil, truncatedEvent(r, offset, UNKOWN_EVENT)
+	default:
+		return nil, &ErrCorruptEvent{Offset: offset, EventType: UNKOWN_EVENT, Err: err}
+	}
+
+	event.header, err = deserializeEventHeader(bytes.NewReader(headerBytes))
+	if err != nil {
+		return nil, &ErrCorruptEvent{Offset: offset, EventType: UNKOWN_EVENT, Err: err}
+	}
+
+	// Until the format description has been read, the header is
+	// assumed to be the v4 minimum
+	headerLength := uint32(EVENT_HEADER_LENGTH)
+	if b.formatDescription != nil {
+		headerLength = uint32(b.formatDescription.HeaderLength)
+	}
+
+	if event.header.Length < headerLength {
+		return nil, &ErrCorruptEvent{
+			Offset:    offset,
+			EventType: event.header.Type,
+			Err:       fmt.Errorf("event length %v is shorter than the event header", event.header.Length),
+		}
+	}
+
+	payload, err := ReadBytes(r, int(event.header.Length - EVENT_HEADER_LENGTH))
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		return nil, truncatedEvent(r, offset, event.header.Type)
+	default:
+		return nil, &ErrCorruptEvent{Offset: offset, EventType: event.header.Type, Err: err}
+	}
+
+	rawPayload := payload
+
+	// Strip the checksum footer before the deserializers see the payload.
+	// The format description carries its own algorithm, and its footer
+	// is dealt with once it has been deserialized below.
+	checksummed := event.header.Type != FORMAT_DESCRIPTION_EVENT && b.checksumAlgorithm() == BINLOG_CHECKSUM_ALG_CRC32
+
+	if checksummed {
+		if len(payload) < int(headerLength - EVENT_HEADER_LENGTH) + BINLOG_CHECKSUM_LENGTH {
+			return nil, &ErrCorruptEvent{
+				Offset:    offset,
+				EventType: event.header.Type,
+				Err:       fmt.Errorf("event length %v leaves no room for the checksum", event.header.Length),
+			}
+		}
+
+		if err = b.verifyChecksum(offset, event.header.Type, headerBytes, payload); err != nil {
+			return nil, err
+		}
+
+		payload = payload[:len(payload) - BINLOG_CHECKSUM_LENGTH]
+	}
+
+	// Anything past the fields we know of in the common header is
+	// skipped along with it
+	payload = payload[headerLength - EVENT_HEADER_LENGTH:]
+
+	b.logger.Log(LOG_LEVEL_DEBUG, "Event",
+		Field("offset", offset),
+		Field("type", event.header.Type),
+		Field("server_id", event.header.ServerId),
+		Field("length", event.header.Length),
+		Field("next_position", event.header.NextPosition),
+	)
+
+	event.data, err = event.header.DataDeserializer().Deserialize(b, bytes.NewReader(payload), event.header)
+	if err != nil {
+		return nil, &ErrCorruptEvent{Offset: offset, EventType: event.header.Type, Err: err}
+	}
+
+	if fde, ok := event.data.(*FormatDescriptionEvent); ok && fde.ChecksumAlgorithm == BINLOG_CHECKSUM_ALG_CRC32 {
+		if err = b.verifyChecksum(offset, event.header.Type, headerBytes, rawPayload); err != nil {
+			return nil, err
+		}
+	}
+
+	return event, nil
+}
+
+func (b *Binlog) checksumAlgorithm() byte {
+	if b.formatDescription == nil {
+		return BINLOG_CHECKSUM_ALG_OFF
+	}
+
+	return b.formatDescription.ChecksumAlgorithm
+}
+
+// Checks the CRC32 footer at the end of payload against the header and
+// the rest of the payload
+func (b *Binlog) verifyChecksum(offset int64, typeCode byte, header, payload []byte) error {
+	if !b.verifyChecksums {
+		return nil
+	}
 
-	event.header = deserializeEventHeader(r)
-	fmt.Println("Event:")
-	fmt.Println("  Head:", event.header)
-	fmt.Println("  Type:", event.header.Type)
-	event.data   = event.header.DataDeserializer().Deserialize(r, event.header)
+	footer := len(payload) - BINLOG_CHECKSUM_LENGTH
+	expected := binary.LittleEndian.Uint32(payload[footer:])
 
-	currentPos, err := r.Seek(0, 1)
-	fatalErr(err)
+	checksum := crc32.NewIEEE()
+	checksum.Write(header)
+	checksum.Write(payload[:footer])
 
-	if currentPos != int64(event.header.NextPosition) {
-		_, err = r.Seek(int64(event.header.NextPosition), 0)
-		// Alternative, slightly faster:
-		// _, err = r.Seek(int64(event.header.NextPosition) - currentPos, 1)
+	if actual := checksum.Sum32(); actual != expected {
+		return &ErrChecksumMismatch{Offset: offset, EventType: typeCode, Expected: expected, Actual: actual}
 	}
 
-	return event
+	return nil
 }
@@ -1,9 +1,8 @@
-package main
+package binlog
 
 import (
 	"bytes"
 	"encoding/binary"
-	"fmt"
 	"io"
 )
 
@@ -17,32 +16,53 @@ type EventHeader struct {
 }
 
 // TODO: move this over to use encoding/binary with struct pointer
-func deserializeEventHeader(r io.Reader) *EventHeader {
+func deserializeEventHeader(r io.Reader) (*EventHeader, error) {
 	// Read number of bytes in header
-	b, err := ReadBytes(r, 4 + 1 + 4 + 4 + 4 + 2)
-	fatalErr(err)
+	b, err := ReadBytes(r, EVENT_HEADER_LENGTH)
+	if err != nil {
+		return nil, err
+	}
 
 	var h EventHeader
-	fatalErr(binary.Read(bytes.NewBuffer(b), binary.LittleEndian, &h))
+	if err = binary.Read(bytes.NewBuffer(b), binary.LittleEndian, &h); err != nil {
+		return nil, err
+	}
 
-	return &h
+	return &h, nil
 }
 
 func (h *EventHeader) DataDeserializer() EventDeserializer {
 	switch h.Type {
-	case WRITE_ROWS_EVENTv0, UPDATE_ROWS_EVENTv0, DELETE_ROWS_EVENTv0,
-	  WRITE_ROWS_EVENTv1, UPDATE_ROWS_EVENTv1, DELETE_ROWS_EVENTv1,
-	  WRITE_ROWS_EVENTv2, UPDATE_ROWS_EVENTv2, DELETE_ROWS_EVENTv2:
+	case WRITE_ROWS_EVENTv0, DELETE_ROWS_EVENTv0,
+	  WRITE_ROWS_EVENTv1, DELETE_ROWS_EVENTv1,
+	  WRITE_ROWS_EVENTv2, DELETE_ROWS_EVENTv2:
 		return &RowsEventDeserializer{}
 
+	case UPDATE_ROWS_EVENTv0, UPDATE_ROWS_EVENTv1, UPDATE_ROWS_EVENTv2:
+		return &UpdateRowsEventDeserializer{}
+
 	case TABLE_MAP_EVENT:
 		return &TableMapEventDeserializer{}
 
-	default:
-		fmt.Println("unsupported event data deserialization:", h.Type)
+	case QUERY_EVENT:
+		return &QueryEventDeserializer{}
+
+	case ROTATE_EVENT:
+		return &RotateEventDeserializer{}
+
+	case XID_EVENT:
+		return &XidEventDeserializer{}
 
+	case GTID_EVENT, ANONYMOUS_GTID_EVENT:
+		return &GtidEventDeserializer{}
+
+	case PREVIOUS_GTIDS_EVENT:
+		return &PreviousGtidsEventDeserializer{}
+
+	case FORMAT_DESCRIPTION_EVENT:
+		return &FormatDescriptionEventDeserializer{}
+
+	default:
 		return &SkipEventDeserializer{}
 	}
-
-	return nil
 }
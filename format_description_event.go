@@ -0,0 +1,154 @@
+package binlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type FormatDescriptionEvent struct {
+	BinlogVersion     uint16
+	ServerVersion     string
+	CreateTimestamp   uint32
+	HeaderLength      uint8
+	PostHeaderLengths []byte // indexed by event type code - 1
+	ChecksumAlgorithm byte
+}
+
+// Returns the length of the fixed data part (post-header) that the server
+// writes for the given event type, or 0 if the type is unknown to it
+func (e *FormatDescriptionEvent) PostHeaderLength(typeCode byte) uint8 {
+	if typeCode == 0 || int(typeCode) > len(e.PostHeaderLengths) {
+		return 0
+	}
+
+	return e.PostHeaderLengths[typeCode - 1]
+}
+
+// Table ids were 4 bytes before MySQL 5.1.4, which shows up as a 2 byte
+// shorter post-header on the events carrying them
+func (e *FormatDescriptionEvent) tableIdLength(typeCode byte) int {
+	if e.PostHeaderLength(typeCode) == 6 {
+		return 4
+	}
+
+	return 6
+}
+
+type FormatDescriptionEventDeserializer struct {}
+
+/*
+FORMAT DESCRIPTION DATA
+=======================
+
+Always the first event of a v4 binlog. It describes how every other
+event in the file is laid out, so it is kept on the Binlog for the
+other deserializers to consult.
+
+Let:
+N = number of event types the server knows about
+C = 5 if the server supports checksums (5.6.1+), 0 otherwise
+
+Fixed:
+2 bytes  = binlog version
+50 bytes = server version (null padded)
+4 bytes  = create timestamp
+1 byte   = common header length (19 for v4)
+N bytes  = post-header length for each event type, starting at type 1
+C bytes  = 1 byte checksum algorithm + 4 byte checksum
+
+*/
+
+func (d *FormatDescriptionEventDeserializer) Deserialize(b *Binlog, reader io.ReadSeeker, header *EventHeader) (EventData, error) {
+	e := new(FormatDescriptionEvent)
+
+	var err error
+
+	e.BinlogVersion, err = ReadUint16(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serverVersion, err := ReadBytes(reader, 50)
+	if err != nil {
+		return nil, err
+	}
+
+	e.ServerVersion = string(bytes.TrimRight(serverVersion, "\x00"))
+
+	e.CreateTimestamp, err = ReadUint32(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	e.HeaderLength, err = ReadUint8(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.HeaderLength < EVENT_HEADER_LENGTH {
+		return nil, fmt.Errorf("common header length %v is shorter than the v4 minimum of %v", e.HeaderLength, EVENT_HEADER_LENGTH)
+	}
+
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if serverSupportsChecksums(e.ServerVersion) {
+		if len(rest) < 5 {
+			return nil, fmt.Errorf("format description too short for checksum algorithm: %v bytes left", len(rest))
+		}
+
+		e.ChecksumAlgorithm = rest[len(rest) - 5]
+		rest = rest[:len(rest) - 5]
+	} else {
+		e.ChecksumAlgorithm = BINLOG_CHECKSUM_ALG_OFF
+	}
+
+	e.PostHeaderLengths = rest
+
+	// Everything read from here on is described by this event
+	b.formatDescription = e
+
+	b.logger.Log(LOG_LEVEL_DEBUG, "Format description event",
+		Field("binlog_version", e.BinlogVersion),
+		Field("server_version", e.ServerVersion),
+		Field("header_length", e.HeaderLength),
+		Field("event_types", len(e.PostHeaderLengths)),
+		Field("checksum_algorithm", e.ChecksumAlgorithm),
+	)
+
+	return e, nil
+}
+
+// Checksums (and the algorithm byte at the end of the format
+// description) were added in MySQL 5.6.1 and MariaDB 5.3
+func serverSupportsChecksums(serverVersion string) bool {
+	major, minor, patch := splitServerVersion(serverVersion)
+
+	if strings.Contains(serverVersion, "MariaDB") {
+		return major > 5 || (major == 5 && minor >= 3)
+	}
+
+	return major > 5 || (major == 5 && (minor > 6 || (minor == 6 && patch >= 1)))
+}
+
+// Pulls the numeric major.minor.patch out of strings like "5.6.20-log"
+func splitServerVersion(serverVersion string) (int, int, int) {
+	parts := strings.SplitN(serverVersion, ".", 3)
+	numbers := [3]int{}
+
+	for i, part := range parts {
+		end := strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' })
+		if end >= 0 {
+			part = part[:end]
+		}
+
+		numbers[i], _ = strconv.Atoi(part)
+	}
+
+	return numbers[0], numbers[1], numbers[2]
+}
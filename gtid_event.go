@@ -0,0 +1,133 @@
+package binlog
+
+import (
+	"fmt"
+	"io"
+)
+
+// Logical clock type code in 5.7+ GTID events
+const LOGICAL_TIMESTAMP_TYPECODE = 2
+
+// Precedes every transaction when gtid_mode is ON
+type GtidEvent struct {
+	CommitFlag bool // true unless the transaction was a single DDL/non-transactional statement
+	SID        SID
+	GNO        int64
+
+	// Logical clock used by multi-threaded replicas (MySQL 5.7+),
+	// both are 0 when the server did not write them
+	LastCommitted  int64
+	SequenceNumber int64
+}
+
+// Formats the GTID as source_id:transaction_id
+func (e *GtidEvent) String() string {
+	return fmt.Sprintf("%v:%v", e.SID, e.GNO)
+}
+
+// Precedes every transaction when gtid_mode is OFF (MySQL 5.7+), carrying
+// the logical clock but no real GTID
+type AnonymousGtidEvent struct {
+	GtidEvent
+}
+
+// The GTIDs executed before this log file was started
+type PreviousGtidsEvent struct {
+	Set GTIDSet
+}
+
+type GtidEventDeserializer struct {}
+
+/*
+GTID EVENT DATA
+===============
+
+Shared by GTID_EVENT and ANONYMOUS_GTID_EVENT (the latter has an
+all zero SID and GNO).
+
+Fixed (post-header):
+1 byte   = commit flag
+16 bytes = SID
+8 bytes  = GNO
+5.7+ only:
+1 byte   = logical timestamp type code (LOGICAL_TIMESTAMP_TYPECODE)
+8 bytes  = last committed
+8 bytes  = sequence number
+
+Anything after that (commit timestamps and transaction length in 8.0)
+is skipped.
+
+*/
+
+func (d *GtidEventDeserializer) Deserialize(b *Binlog, reader io.ReadSeeker, header *EventHeader) (EventData, error) {
+	e := GtidEvent{}
+
+	flag, err := ReadUint8(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	e.CommitFlag = flag != 0
+
+	sid, err := ReadBytes(reader, len(e.SID))
+	if err != nil {
+		return nil, err
+	}
+
+	copy(e.SID[:], sid)
+
+	gno, err := ReadUint64(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	e.GNO = int64(gno)
+
+	// Older servers end the event here
+	typeCode, err := ReadUint8(reader)
+	if err == nil && typeCode == LOGICAL_TIMESTAMP_TYPECODE {
+		lastCommitted, err := ReadUint64(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		sequenceNumber, err := ReadUint64(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		e.LastCommitted = int64(lastCommitted)
+		e.SequenceNumber = int64(sequenceNumber)
+	} else if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	b.logger.Log(LOG_LEVEL_DEBUG, "Gtid event",
+		Field("type", header.Type),
+		Field("gtid", &e),
+		Field("last_committed", e.LastCommitted),
+		Field("sequence_number", e.SequenceNumber),
+	)
+
+	if header.Type == ANONYMOUS_GTID_EVENT {
+		return &AnonymousGtidEvent{e}, nil
+	}
+
+	return &e, nil
+}
+
+type PreviousGtidsEventDeserializer struct {}
+
+// The payload is a GTID set in binary form, see ReadGTIDSet
+func (d *PreviousGtidsEventDeserializer) Deserialize(b *Binlog, reader io.ReadSeeker, header *EventHeader) (EventData, error) {
+	set, err := ReadGTIDSet(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	b.previousGtids = set
+
+	b.logger.Log(LOG_LEVEL_DEBUG, "Previous gtids event", Field("sids", len(set)))
+
+	return &PreviousGtidsEvent{Set: set}, nil
+}
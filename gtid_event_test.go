@@ -0,0 +1,86 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testSID = SID{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}
+
+func gtidTestEvent(typeCode byte, gno int64, logicalClock bool) testEvent {
+	payload := new(bytes.Buffer)
+	payload.WriteByte(1)
+	payload.Write(testSID[:])
+	binary.Write(payload, binary.LittleEndian, gno)
+
+	if logicalClock {
+		payload.WriteByte(LOGICAL_TIMESTAMP_TYPECODE)
+		binary.Write(payload, binary.LittleEndian, int64(gno - 1))
+		binary.Write(payload, binary.LittleEndian, int64(gno))
+	}
+
+	return testEvent{typeCode, payload.Bytes()}
+}
+
+func TestGtidEvents(t *testing.T) {
+	previous := new(bytes.Buffer)
+	binary.Write(previous, binary.LittleEndian, uint64(1))
+	previous.Write(testSID[:])
+	binary.Write(previous, binary.LittleEndian, uint64(2))
+	binary.Write(previous, binary.LittleEndian, []uint64{1, 6, 11, 19})
+
+	b := openTestBinlog(t, makeChecksummedTestBinlog(
+		testEvent{PREVIOUS_GTIDS_EVENT, previous.Bytes()},
+		gtidTestEvent(GTID_EVENT, 19, false),
+		gtidTestEvent(GTID_EVENT, 20, true),
+		gtidTestEvent(ANONYMOUS_GTID_EVENT, 0, true),
+	))
+
+	event, err := b.NextEvent()
+	checkErr(t, err)
+
+	expected := GTIDSet{testSID: {{1, 6}, {11, 19}}}
+	assert.Equal(t, expected, event.Data().(*PreviousGtidsEvent).Set)
+	assert.Equal(t, expected, b.PreviousGtids())
+
+	event, err = b.NextEvent()
+	checkErr(t, err)
+
+	gtid := event.Data().(*GtidEvent)
+	assert.True(t, gtid.CommitFlag)
+	assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:19", gtid.String())
+	assert.Equal(t, int64(0), gtid.SequenceNumber)
+
+	event, err = b.NextEvent()
+	checkErr(t, err)
+
+	gtid = event.Data().(*GtidEvent)
+	assert.Equal(t, int64(20), gtid.GNO)
+	assert.Equal(t, int64(19), gtid.LastCommitted)
+	assert.Equal(t, int64(20), gtid.SequenceNumber)
+
+	event, err = b.NextEvent()
+	checkErr(t, err)
+
+	_, ok := event.Data().(*AnonymousGtidEvent)
+	assert.True(t, ok, "expected *AnonymousGtidEvent, got %T", event.Data())
+}
+
+func TestTransactionGtid(t *testing.T) {
+	b := openTestBinlog(t, makeTestBinlog(
+		gtidTestEvent(GTID_EVENT, 7, true),
+		queryTestEvent("shop", "BEGIN", nil),
+		queryTestEvent("shop", "DELETE FROM t", nil),
+		xidTestEvent(3),
+	))
+
+	transaction, err := b.NextTransaction()
+	checkErr(t, err)
+
+	if assert.NotNil(t, transaction.Gtid) {
+		assert.Equal(t, int64(7), transaction.Gtid.GNO)
+	}
+}
@@ -0,0 +1,414 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A server UUID (source id), the first half of a GTID
+type SID [16]byte
+
+// Parses a SID in the usual dashed UUID form, or as 32 plain hex digits
+func ParseSID(s string) (SID, error) {
+	var sid SID
+
+	h := strings.ReplaceAll(strings.TrimSpace(s), "-", "")
+	if len(h) != 2 * len(sid) {
+		return sid, fmt.Errorf("invalid SID %q: expected 32 hex digits", s)
+	}
+
+	if _, err := hex.Decode(sid[:], []byte(h)); err != nil {
+		return sid, fmt.Errorf("invalid SID %q: %v", s, err)
+	}
+
+	return sid, nil
+}
+
+// Formats the SID the way MySQL does: 3e11fa47-71ca-11e1-9e33-c80aa9429562
+func (s SID) String() string {
+	h := hex.EncodeToString(s[:])
+	return fmt.Sprintf("%v-%v-%v-%v-%v", h[0:8], h[8:12], h[12:16], h[16:20], h[20:32])
+}
+
+// A run of transaction numbers. Start is included and End is not, the
+// same as in the binary encoding (the text form has an inclusive end).
+type GTIDInterval struct {
+	Start int64
+	End   int64
+}
+
+func (i GTIDInterval) String() string {
+	if i.End - i.Start == 1 {
+		return strconv.FormatInt(i.Start, 10)
+	}
+
+	return fmt.Sprintf("%v-%v", i.Start, i.End - 1)
+}
+
+/*
+GTID SETS
+=========
+
+A GTIDSet maps each SID to its transaction numbers, kept as sorted,
+non-overlapping, non-adjacent intervals with no empty entries. Every
+function and method here returns sets in that form, so two sets holding
+the same GTIDs also compare equal with reflect.DeepEqual.
+
+The set operations never modify their receiver or argument, they
+return a new set.
+
+Text form, as used by gtid_executed and friends:
+
+3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:11-18,2174b383-5441-11e8-b90a-c80aa9429562:1
+
+*/
+
+// Sets of transaction numbers keyed by the server they originated on
+type GTIDSet map[SID][]GTIDInterval
+
+// Parses the text form of a GTID set. Whitespace around the entries
+// (MySQL puts a newline after each comma) is ignored, and an empty
+// string gives an empty set.
+func ParseGTIDSet(s string) (GTIDSet, error) {
+	set := make(GTIDSet)
+
+	if strings.TrimSpace(s) == "" {
+		return set, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+
+		if len(parts) < 2 {
+			return nil, fmt.Errorf("invalid GTID set entry %q: expected sid:interval", entry)
+		}
+
+		sid, err := ParseSID(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		for _, part := range parts[1:] {
+			interval, err := parseGTIDInterval(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid GTID set entry %q: %v", entry, err)
+			}
+
+			set[sid] = append(set[sid], interval)
+		}
+	}
+
+	return set.normalize(), nil
+}
+
+// Parses "N" or "N-M" (inclusive)
+func parseGTIDInterval(s string) (GTIDInterval, error) {
+	bounds := strings.SplitN(strings.TrimSpace(s), "-", 2)
+
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil {
+		return GTIDInterval{}, fmt.Errorf("bad interval %q", s)
+	}
+
+	last := start
+
+	if len(bounds) == 2 {
+		last, err = strconv.ParseInt(bounds[1], 10, 64)
+		if err != nil {
+			return GTIDInterval{}, fmt.Errorf("bad interval %q", s)
+		}
+	}
+
+	if start < 1 || last < start {
+		return GTIDInterval{}, fmt.Errorf("bad interval %q", s)
+	}
+
+	return GTIDInterval{Start: start, End: last + 1}, nil
+}
+
+// Returns a set holding just sid:gno
+func NewGTIDSet(sid SID, gno int64) GTIDSet {
+	return GTIDSet{sid: {{Start: gno, End: gno + 1}}}
+}
+
+// Returns the SIDs in the set in ascending order
+func (set GTIDSet) SIDs() []SID {
+	sids := make([]SID, 0, len(set))
+
+	for sid := range set {
+		sids = append(sids, sid)
+	}
+
+	sort.Slice(sids, func(i, j int) bool {
+		return bytes.Compare(sids[i][:], sids[j][:]) < 0
+	})
+
+	return sids
+}
+
+// Formats the set in the text form, SIDs in ascending order
+func (set GTIDSet) String() string {
+	entries := []string{}
+
+	for _, sid := range set.SIDs() {
+		entry := sid.String()
+
+		for _, interval := range set[sid] {
+			entry += ":" + interval.String()
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return strings.Join(entries, ",")
+}
+
+func (set GTIDSet) Clone() GTIDSet {
+	clone := make(GTIDSet, len(set))
+
+	for sid, intervals := range set {
+		clone[sid] = append([]GTIDInterval{}, intervals...)
+	}
+
+	return clone
+}
+
+// Sorts and merges each SID's intervals and drops empty entries, in place
+func (set GTIDSet) normalize() GTIDSet {
+	for sid, intervals := range set {
+		merged := mergeGTIDIntervals(intervals)
+
+		if len(merged) == 0 {
+			delete(set, sid)
+		} else {
+			set[sid] = merged
+		}
+	}
+
+	return set
+}
+
+func mergeGTIDIntervals(intervals []GTIDInterval) []GTIDInterval {
+	sorted := []GTIDInterval{}
+
+	for _, interval := range intervals {
+		if interval.End > interval.Start {
+			sorted = append(sorted, interval)
+		}
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Start < sorted[j].Start
+	})
+
+	merged := []GTIDInterval{}
+
+	for _, interval := range sorted {
+		last := len(merged) - 1
+
+		if last >= 0 && interval.Start <= merged[last].End {
+			if interval.End > merged[last].End {
+				merged[last].End = interval.End
+			}
+		} else {
+			merged = append(merged, interval)
+		}
+	}
+
+	return merged
+}
+
+// Returns the GTIDs in either set
+func (set GTIDSet) Union(other GTIDSet) GTIDSet {
+	union := set.Clone()
+
+	for sid, intervals := range other {
+		union[sid] = append(union[sid], intervals...)
+	}
+
+	return union.normalize()
+}
+
+// Returns the GTIDs in both sets
+func (set GTIDSet) Intersect(other GTIDSet) GTIDSet {
+	intersection := make(GTIDSet)
+
+	for sid, intervals := range set {
+		theirs := other[sid]
+		result := []GTIDInterval{}
+
+		for i, j := 0, 0; i < len(intervals) && j < len(theirs); {
+			start := max(intervals[i].Start, theirs[j].Start)
+			end := min(intervals[i].End, theirs[j].End)
+
+			if start < end {
+				result = append(result, GTIDInterval{Start: start, End: end})
+			}
+
+			if intervals[i].End < theirs[j].End {
+				i++
+			} else {
+				j++
+			}
+		}
+
+		intersection[sid] = result
+	}
+
+	return intersection.normalize()
+}
+
+// Returns the GTIDs in set that are not in other
+func (set GTIDSet) Subtract(other GTIDSet) GTIDSet {
+	difference := make(GTIDSet)
+
+	for sid, intervals := range set {
+		result := []GTIDInterval{}
+
+		for _, interval := range intervals {
+			remaining := []GTIDInterval{interval}
+
+			for _, remove := range other[sid] {
+				next := []GTIDInterval{}
+
+				for _, r := range remaining {
+					if remove.End <= r.Start || remove.Start >= r.End {
+						next = append(next, r)
+						continue
+					}
+
+					if remove.Start > r.Start {
+						next = append(next, GTIDInterval{Start: r.Start, End: remove.Start})
+					}
+
+					if remove.End < r.End {
+						next = append(next, GTIDInterval{Start: remove.End, End: r.End})
+					}
+				}
+
+				remaining = next
+			}
+
+			result = append(result, remaining...)
+		}
+
+		difference[sid] = result
+	}
+
+	return difference.normalize()
+}
+
+// Reports whether every GTID in other is also in set
+func (set GTIDSet) Contains(other GTIDSet) bool {
+	return len(other.Subtract(set)) == 0
+}
+
+// Reports whether sid:gno is in the set
+func (set GTIDSet) ContainsGTID(sid SID, gno int64) bool {
+	for _, interval := range set[sid] {
+		if gno >= interval.Start && gno < interval.End {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether both sets hold exactly the same GTIDs
+func (set GTIDSet) Equal(other GTIDSet) bool {
+	return set.Contains(other) && other.Contains(set)
+}
+
+/*
+GTID SET BINARY FORMAT
+======================
+
+Used by PREVIOUS_GTIDS_EVENT.
+
+Let:
+S = number of SIDs
+I = number of intervals for a SID
+
+8 bytes = S
+S * (
+	16 bytes = SID
+	8 bytes  = I
+	I * (
+		8 bytes = interval start (inclusive)
+		8 bytes = interval end (exclusive)
+	)
+)
+
+*/
+
+func ReadGTIDSet(r io.Reader) (GTIDSet, error) {
+	sidCount, err := ReadUint64(r)
+	if err != nil {
+		return nil, err
+	}
+
+	set := make(GTIDSet)
+
+	for i := uint64(0); i < sidCount; i++ {
+		var sid SID
+
+		b, err := ReadBytes(r, len(sid))
+		if err != nil {
+			return nil, err
+		}
+
+		copy(sid[:], b)
+
+		intervalCount, err := ReadUint64(r)
+		if err != nil {
+			return nil, err
+		}
+
+		for j := uint64(0); j < intervalCount; j++ {
+			start, err := ReadUint64(r)
+			if err != nil {
+				return nil, err
+			}
+
+			end, err := ReadUint64(r)
+			if err != nil {
+				return nil, err
+			}
+
+			if end <= start {
+				return nil, fmt.Errorf("invalid GTID interval %v-%v for %v", start, end, sid)
+			}
+
+			set[sid] = append(set[sid], GTIDInterval{Start: int64(start), End: int64(end)})
+		}
+	}
+
+	return set.normalize(), nil
+}
+
+// Encodes the set in the PREVIOUS_GTIDS_EVENT binary format, SIDs in
+// ascending order. Implements encoding.BinaryMarshaler.
+func (set GTIDSet) MarshalBinary() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	sids := set.SIDs()
+
+	binary.Write(buf, binary.LittleEndian, uint64(len(sids)))
+
+	for _, sid := range sids {
+		buf.Write(sid[:])
+		binary.Write(buf, binary.LittleEndian, uint64(len(set[sid])))
+
+		for _, interval := range set[sid] {
+			binary.Write(buf, binary.LittleEndian, interval.Start)
+			binary.Write(buf, binary.LittleEndian, interval.End)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,166 @@
+package binlog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const otherSIDText = "2174b383-5441-11e8-b90a-c80aa9429562"
+
+func mustParseGTIDSet(t *testing.T, s string) GTIDSet {
+	set, err := ParseGTIDSet(s)
+	checkErr(t, err)
+	return set
+}
+
+func TestParseSID(t *testing.T) {
+	for _, s := range []string{
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562",
+		"3E11FA47-71CA-11E1-9E33-C80AA9429562",
+		"3e11fa4771ca11e19e33c80aa9429562",
+	} {
+		sid, err := ParseSID(s)
+		checkErr(t, err)
+		assert.Equal(t, testSID, sid, s)
+	}
+
+	for _, s := range []string{"", "3e11fa47", "3e11fa47-71ca-11e1-9e33-c80aa942956z", "3e11fa47-71ca-11e1-9e33-c80aa942956200"} {
+		_, err := ParseSID(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseGTIDSet(t *testing.T) {
+	set := mustParseGTIDSet(t, "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:11-18")
+	assert.Equal(t, GTIDSet{testSID: {{1, 6}, {11, 19}}}, set)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"  \n", ""},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:7", "3e11fa47-71ca-11e1-9e33-c80aa9429562:7"},
+		// Overlapping, adjacent and out of order intervals are merged
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:11-18:1-5:6-7:3-4", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-7:11-18"},
+		// Repeated SIDs are combined
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-2,3e11fa47-71ca-11e1-9e33-c80aa9429562:3", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-3"},
+		// SIDs are sorted, whitespace after commas is ignored
+		{
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5,\n" + otherSIDText + ":1",
+			otherSIDText + ":1,3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5",
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, mustParseGTIDSet(t, test.input).String(), test.input)
+	}
+
+	for _, input := range []string{
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:0",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:5-1",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:a",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:-3",
+		"3e11fa47:1",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:1,",
+	} {
+		_, err := ParseGTIDSet(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestGTIDSetArithmetic(t *testing.T) {
+	sid := "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+	tests := []struct {
+		a, b      string
+		union     string
+		intersect string
+		subtract  string
+	}{
+		{"", "", "", "", ""},
+		{sid + ":1-5", "", sid + ":1-5", "", sid + ":1-5"},
+		{"", sid + ":1-5", sid + ":1-5", "", ""},
+		{sid + ":1-5", sid + ":1-5", sid + ":1-5", sid + ":1-5", ""},
+		{sid + ":1-5", sid + ":6-10", sid + ":1-10", "", sid + ":1-5"},
+		{sid + ":1-5", sid + ":7-10", sid + ":1-5:7-10", "", sid + ":1-5"},
+		{sid + ":1-10", sid + ":3-4", sid + ":1-10", sid + ":3-4", sid + ":1-2:5-10"},
+		{sid + ":3-4", sid + ":1-10", sid + ":1-10", sid + ":3-4", ""},
+		{sid + ":1-5", sid + ":4-8", sid + ":1-8", sid + ":4-5", sid + ":1-3"},
+		{sid + ":1-5:11-18", sid + ":3-12:15", sid + ":1-18", sid + ":3-5:11-12:15", sid + ":1-2:13-14:16-18"},
+		{sid + ":1-20", sid + ":2:4:6-7:20", sid + ":1-20", sid + ":2:4:6-7:20", sid + ":1:3:5:8-19"},
+		{
+			sid + ":1-5," + otherSIDText + ":1-3",
+			otherSIDText + ":2",
+			otherSIDText + ":1-3," + sid + ":1-5",
+			otherSIDText + ":2",
+			otherSIDText + ":1:3," + sid + ":1-5",
+		},
+		{sid + ":1-5", otherSIDText + ":1-5", otherSIDText + ":1-5," + sid + ":1-5", "", sid + ":1-5"},
+	}
+
+	for _, test := range tests {
+		a := mustParseGTIDSet(t, test.a)
+		b := mustParseGTIDSet(t, test.b)
+		aText, bText := a.String(), b.String()
+
+		assert.Equal(t, test.union, a.Union(b).String(), "%v + %v", test.a, test.b)
+		assert.Equal(t, test.union, b.Union(a).String(), "%v + %v", test.b, test.a)
+		assert.Equal(t, test.intersect, a.Intersect(b).String(), "%v & %v", test.a, test.b)
+		assert.Equal(t, test.intersect, b.Intersect(a).String(), "%v & %v", test.b, test.a)
+		assert.Equal(t, test.subtract, a.Subtract(b).String(), "%v - %v", test.a, test.b)
+
+		assert.True(t, a.Union(b).Contains(a))
+		assert.True(t, a.Union(b).Contains(b))
+		assert.True(t, a.Contains(a.Intersect(b)))
+		assert.True(t, a.Equal(a.Subtract(b).Union(a.Intersect(b))))
+		assert.Equal(t, test.subtract == "", b.Contains(a), "%v contains %v", test.b, test.a)
+
+		// The operands are left alone
+		assert.Equal(t, aText, a.String())
+		assert.Equal(t, bText, b.String())
+	}
+}
+
+func TestGTIDSetContainsGTID(t *testing.T) {
+	set := mustParseGTIDSet(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:11-18")
+
+	for gno, expected := range map[int64]bool{0: false, 1: true, 5: true, 6: false, 10: false, 11: true, 18: true, 19: false} {
+		assert.Equal(t, expected, set.ContainsGTID(testSID, gno), "gno %v", gno)
+	}
+
+	assert.False(t, set.ContainsGTID(SID{}, 1))
+	assert.True(t, NewGTIDSet(testSID, 3).Equal(mustParseGTIDSet(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:3")))
+	assert.True(t, set.Contains(GTIDSet{}))
+	assert.False(t, GTIDSet{}.Contains(set))
+	assert.False(t, set.Equal(set.Subtract(NewGTIDSet(testSID, 3))))
+}
+
+func TestGTIDSetBinary(t *testing.T) {
+	set := mustParseGTIDSet(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:11-18," + otherSIDText + ":7")
+
+	data, err := set.MarshalBinary()
+	checkErr(t, err)
+
+	// count, then each SID (ascending) with its interval count and intervals
+	assert.Equal(t, 8 + (16 + 8 + 16) + (16 + 8 + 2 * 16), len(data))
+	assert.Equal(t, []byte{2, 0, 0, 0, 0, 0, 0, 0}, data[:8])
+	assert.Equal(t, byte(0x21), data[8])
+
+	decoded, err := ReadGTIDSet(bytes.NewReader(data))
+	checkErr(t, err)
+	assert.Equal(t, set, decoded)
+
+	data, err = GTIDSet{}.MarshalBinary()
+	checkErr(t, err)
+	assert.Equal(t, []byte{0, 0, 0, 0, 0, 0, 0, 0}, data)
+
+	// Truncated input
+	_, err = ReadGTIDSet(bytes.NewReader([]byte{1, 0, 0, 0, 0, 0, 0, 0, 0x3e}))
+	assert.Error(t, err)
+}
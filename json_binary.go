@@ -0,0 +1,422 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Binary JSON value types
+const (
+	JSONB_TYPE_SMALL_OBJECT byte = 0x00
+	JSONB_TYPE_LARGE_OBJECT byte = 0x01
+	JSONB_TYPE_SMALL_ARRAY  byte = 0x02
+	JSONB_TYPE_LARGE_ARRAY  byte = 0x03
+	JSONB_TYPE_LITERAL      byte = 0x04
+	JSONB_TYPE_INT16        byte = 0x05
+	JSONB_TYPE_UINT16       byte = 0x06
+	JSONB_TYPE_INT32        byte = 0x07
+	JSONB_TYPE_UINT32       byte = 0x08
+	JSONB_TYPE_INT64        byte = 0x09
+	JSONB_TYPE_UINT64       byte = 0x0a
+	JSONB_TYPE_DOUBLE       byte = 0x0b
+	JSONB_TYPE_STRING       byte = 0x0c
+	JSONB_TYPE_OPAQUE       byte = 0x0f
+)
+
+// The deepest nesting of arrays and objects MySQL allows in a document
+const JSON_DOCUMENT_MAX_DEPTH = 100
+
+// JSONB_TYPE_LITERAL values
+const (
+	JSONB_NULL_LITERAL  byte = 0x00
+	JSONB_TRUE_LITERAL  byte = 0x01
+	JSONB_FALSE_LITERAL byte = 0x02
+)
+
+/*
+BINARY JSON FORMAT
+==================
+
+A JSON column holds a length (of the metadata's pack size) followed by
+the document: a 1 byte type (JSONB_TYPE_*) and then the value.
+
+Objects and arrays come in a small form, with 2 byte counts, sizes and
+offsets, and a large form where those take 4 bytes. Let O be that size.
+
+O bytes = number of elements
+O bytes = size of the value in bytes
+objects only, per element (sorted by key length, then key):
+	O bytes = key offset
+	2 bytes = key length
+per element:
+	1 byte  = value type
+	O bytes = value offset, or the value itself if it fits: literals,
+	          INT16 and UINT16 always, INT32 and UINT32 in the large form
+keys
+values
+
+Offsets count from the start of the object or array, just past its
+type byte, and always point past the entries.
+
+Scalars:
+LITERAL          1 byte, JSONB_*_LITERAL
+INT16 .. UINT64  2, 4 or 8 byte little endian integers
+DOUBLE           8 byte little endian IEEE 754
+STRING           variable length size, then utf8mb4 bytes
+OPAQUE           1 byte MySQL type, variable length size, then the bytes
+
+Variable length sizes take 1 to 5 bytes, 7 bits each, least significant
+first, with the high bit set on all but the last byte.
+
+Opaque values are MySQL types without a JSON counterpart. DECIMAL holds
+a precision byte, a decimals byte and the NEWDECIMAL bytes. DATE,
+DATETIME, TIMESTAMP and TIME hold MySQL's 8 byte packed temporal.
+
+*/
+
+// A decoded JSON value and its text, see JsonRowImageCell
+type jsonDocument struct {
+	value interface{}
+	text  string
+}
+
+// Decodes a binary JSON document. An empty document is JSON null, which
+// is what MySQL writes for a JSON NULL set by some partial updates.
+func decodeBinaryJSON(data []byte) (jsonDocument, error) {
+	if len(data) == 0 {
+		return jsonDocument{nil, "null"}, nil
+	}
+
+	return decodeJSONValue(data[0], data[1:], 0)
+}
+
+// depth is the number of arrays and objects the value is nested in
+func decodeJSONValue(valueType byte, data []byte, depth int) (jsonDocument, error) {
+	switch valueType {
+	case JSONB_TYPE_SMALL_OBJECT, JSONB_TYPE_LARGE_OBJECT:
+		return decodeJSONContainer(data, valueType == JSONB_TYPE_LARGE_OBJECT, true, depth + 1)
+
+	case JSONB_TYPE_SMALL_ARRAY, JSONB_TYPE_LARGE_ARRAY:
+		return decodeJSONContainer(data, valueType == JSONB_TYPE_LARGE_ARRAY, false, depth + 1)
+
+	case JSONB_TYPE_LITERAL:
+		if len(data) < 1 {
+			return jsonDocument{}, fmt.Errorf("JSON literal is missing its value")
+		}
+
+		switch data[0] {
+		case JSONB_NULL_LITERAL:
+			return jsonDocument{nil, "null"}, nil
+		case JSONB_TRUE_LITERAL:
+			return jsonDocument{true, "true"}, nil
+		case JSONB_FALSE_LITERAL:
+			return jsonDocument{false, "false"}, nil
+		}
+
+		return jsonDocument{}, fmt.Errorf("unknown JSON literal %v", data[0])
+
+	case JSONB_TYPE_INT16, JSONB_TYPE_INT32, JSONB_TYPE_INT64:
+		size := jsonIntegerSize(valueType)
+		if len(data) < size {
+			return jsonDocument{}, fmt.Errorf("JSON integer is cut short")
+		}
+
+		// Sign extend from the integer's width
+		shift := uint(64 - 8 * size)
+		v := int64(jsonUint(data[:size]) << shift) >> shift
+
+		return jsonDocument{v, strconv.FormatInt(v, 10)}, nil
+
+	case JSONB_TYPE_UINT16, JSONB_TYPE_UINT32, JSONB_TYPE_UINT64:
+		size := jsonIntegerSize(valueType)
+		if len(data) < size {
+			return jsonDocument{}, fmt.Errorf("JSON integer is cut short")
+		}
+
+		v := jsonUint(data[:size])
+		return jsonDocument{v, strconv.FormatUint(v, 10)}, nil
+
+	case JSONB_TYPE_DOUBLE:
+		if len(data) < 8 {
+			return jsonDocument{}, fmt.Errorf("JSON double is cut short")
+		}
+
+		v := math.Float64frombits(binary.LittleEndian.Uint64(data))
+		return jsonDocument{v, formatJSONDouble(v)}, nil
+
+	case JSONB_TYPE_STRING:
+		b, err := readJSONVariableLengthBytes(data)
+		if err != nil {
+			return jsonDocument{}, err
+		}
+
+		return jsonDocument{string(b), quoteJSONString(string(b))}, nil
+
+	case JSONB_TYPE_OPAQUE:
+		if len(data) < 1 {
+			return jsonDocument{}, fmt.Errorf("JSON opaque value is missing its type")
+		}
+
+		b, err := readJSONVariableLengthBytes(data[1:])
+		if err != nil {
+			return jsonDocument{}, err
+		}
+
+		return decodeJSONOpaque(data[0], b)
+	}
+
+	return jsonDocument{}, fmt.Errorf("unknown JSON value type %v", valueType)
+}
+
+func decodeJSONContainer(data []byte, large bool, isObject bool, depth int) (jsonDocument, error) {
+	if depth > JSON_DOCUMENT_MAX_DEPTH {
+		return jsonDocument{}, fmt.Errorf("JSON document is nested more than %v deep", JSON_DOCUMENT_MAX_DEPTH)
+	}
+
+	offsetSize := 2
+	if large {
+		offsetSize = 4
+	}
+
+	if len(data) < 2 * offsetSize {
+		return jsonDocument{}, fmt.Errorf("JSON container header is cut short")
+	}
+
+	count := int(jsonUint(data[:offsetSize]))
+	size := int(jsonUint(data[offsetSize:2 * offsetSize]))
+
+	if size > len(data) {
+		return jsonDocument{}, fmt.Errorf("JSON container size %v exceeds the %v bytes available", size, len(data))
+	}
+
+	data = data[:size]
+
+	keyEntrySize := 0
+	if isObject {
+		keyEntrySize = offsetSize + 2
+	}
+
+	valueEntrySize := 1 + offsetSize
+	keyEntries := 2 * offsetSize
+	valueEntries := keyEntries + count * keyEntrySize
+
+	// Keys and values follow the entries. An offset back into them would
+	// have the container decode itself forever.
+	entriesEnd := valueEntries + count * valueEntrySize
+
+	if entriesEnd > size {
+		return jsonDocument{}, fmt.Errorf("JSON container with %v elements doesn't fit in %v bytes", count, size)
+	}
+
+	object := map[string]interface{}{}
+	array := []interface{}{}
+	texts := []string{}
+
+	for i := 0; i < count; i++ {
+		key := ""
+
+		if isObject {
+			entry := keyEntries + i * keyEntrySize
+			keyOffset := int(jsonUint(data[entry:entry + offsetSize]))
+			keyLength := int(jsonUint(data[entry + offsetSize:entry + offsetSize + 2]))
+
+			if keyOffset < entriesEnd || keyOffset + keyLength > size {
+				return jsonDocument{}, fmt.Errorf("JSON object key runs past the object")
+			}
+
+			key = string(data[keyOffset:keyOffset + keyLength])
+		}
+
+		entry := valueEntries + i * valueEntrySize
+		valueType := data[entry]
+		inline := data[entry + 1:entry + valueEntrySize]
+
+		var element jsonDocument
+		var err error
+
+		if jsonValueIsInlined(valueType, large) {
+			element, err = decodeJSONValue(valueType, inline, depth)
+		} else {
+			offset := int(jsonUint(inline))
+			if offset < entriesEnd || offset >= size {
+				return jsonDocument{}, fmt.Errorf("JSON value offset %v is outside the container", offset)
+			}
+
+			element, err = decodeJSONValue(valueType, data[offset:], depth)
+		}
+
+		if err != nil {
+			return jsonDocument{}, err
+		}
+
+		if isObject {
+			object[key] = element.value
+			texts = append(texts, quoteJSONString(key) + ": " + element.text)
+		} else {
+			array = append(array, element.value)
+			texts = append(texts, element.text)
+		}
+	}
+
+	if isObject {
+		return jsonDocument{object, "{" + strings.Join(texts, ", ") + "}"}, nil
+	}
+
+	return jsonDocument{array, "[" + strings.Join(texts, ", ") + "]"}, nil
+}
+
+func jsonValueIsInlined(valueType byte, large bool) bool {
+	switch valueType {
+	case JSONB_TYPE_LITERAL, JSONB_TYPE_INT16, JSONB_TYPE_UINT16:
+		return true
+	case JSONB_TYPE_INT32, JSONB_TYPE_UINT32:
+		return large
+	}
+
+	return false
+}
+
+func jsonIntegerSize(valueType byte) int {
+	switch valueType {
+	case JSONB_TYPE_INT16, JSONB_TYPE_UINT16:
+		return 2
+	case JSONB_TYPE_INT32, JSONB_TYPE_UINT32:
+		return 4
+	}
+
+	return 8
+}
+
+// Little endian unsigned integer of up to 8 bytes
+func jsonUint(b []byte) uint64 {
+	v := uint64(0)
+
+	for i := len(b) - 1; i >= 0; i-- {
+		v = v << 8 | uint64(b[i])
+	}
+
+	return v
+}
+
+// Reads a variable length size and returns the bytes it covers
+func readJSONVariableLengthBytes(data []byte) ([]byte, error) {
+	length := uint64(0)
+
+	for i := 0; i < 5; i++ {
+		if i >= len(data) {
+			return nil, fmt.Errorf("JSON variable length size is cut short")
+		}
+
+		length |= uint64(data[i] & 0x7f) << uint(7 * i)
+
+		if data[i] & 0x80 == 0 {
+			start := i + 1
+
+			if uint64(len(data) - start) < length {
+				return nil, fmt.Errorf("JSON value of %v bytes runs past the document", length)
+			}
+
+			return data[start:start + int(length)], nil
+		}
+	}
+
+	return nil, fmt.Errorf("JSON variable length size is too long")
+}
+
+func decodeJSONOpaque(mysqlType byte, data []byte) (jsonDocument, error) {
+	switch mysqlType {
+	case MYSQL_TYPE_NEWDECIMAL:
+		if len(data) < 2 {
+			return jsonDocument{}, fmt.Errorf("JSON decimal is cut short")
+		}
+
+		metadata := &ColumnMetadata{data: data[:2], metaType: NEW_DECIMAL_METADATA}
+
+		unscaled, scale, err := ReadNewDecimal(bytes.NewReader(data[2:]), metadata)
+		if err != nil {
+			return jsonDocument{}, err
+		}
+
+		text := NewDecimalRowImageCell(unscaled, scale).String()
+		return jsonDocument{json.Number(text), text}, nil
+
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME, MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_TIME:
+		if len(data) < 8 {
+			return jsonDocument{}, fmt.Errorf("JSON temporal value is cut short")
+		}
+
+		text := formatPackedTemporal(mysqlType, int64(binary.LittleEndian.Uint64(data)))
+		return jsonDocument{text, quoteJSONString(text)}, nil
+	}
+
+	text := fmt.Sprintf("base64:type%v:%v", mysqlType, base64.StdEncoding.EncodeToString(data))
+	return jsonDocument{text, quoteJSONString(text)}, nil
+}
+
+// Formats MySQL's in-memory packed temporal format:
+// DATE, DATETIME, TIMESTAMP: 1 bit sign, 17 bits year * 13 + month,
+// 5 bits day, 5 bits hour, 6 bits minute, 6 bits second, 24 bits
+// microseconds.
+// TIME: the same with the date bits left 0 and 10 bits of hours.
+func formatPackedTemporal(mysqlType byte, packed int64) string {
+	sign := ""
+	if packed < 0 {
+		sign = "-"
+		packed = -packed
+	}
+
+	microseconds := packed % (1 << 24)
+	ymdhms := packed >> 24
+
+	hms := ymdhms % (1 << 17)
+	second := hms % (1 << 6)
+	minute := (hms >> 6) % (1 << 6)
+
+	if mysqlType == MYSQL_TYPE_TIME {
+		// Hours run into the date bits
+		hour := (ymdhms >> 12) % (1 << 10)
+		return fmt.Sprintf("%v%02d:%02d:%02d.%06d", sign, hour, minute, second, microseconds)
+	}
+
+	hour := hms >> 12
+	ymd := ymdhms >> 17
+	day := ymd % (1 << 5)
+	ym := ymd >> 5
+	month := ym % 13
+	year := ym / 13
+
+	if mysqlType == MYSQL_TYPE_DATE {
+		return fmt.Sprintf("%04d-%02d-%02d", year, month, day)
+	}
+
+	return fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d.%06d", year, month, day, hour, minute, second, microseconds)
+}
+
+// Formats a double the way MySQL does in JSON text: shortest form,
+// always with a decimal point or an exponent
+func formatJSONDouble(v float64) string {
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	s = strings.Replace(s, "e+", "e", 1)
+
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+
+	return s
+}
+
+func quoteJSONString(s string) string {
+	buf := new(bytes.Buffer)
+
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,139 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeBinaryJSON(t *testing.T) {
+	tests := []struct {
+		data  []byte
+		text  string
+		value interface{}
+	}{
+		{
+			[]byte{0x00, 0x01, 0x00, 0x0c, 0x00, 0x0b, 0x00, 0x01, 0x00, 0x05, 0x01, 0x00, 0x61},
+			`{"a": 1}`,
+			map[string]interface{}{"a": int64(1)},
+		},
+		{
+			[]byte{
+				0x00, 0x03, 0x00, 0x4b, 0x00, 0x19, 0x00, 0x02, 0x00, 0x1b, 0x00, 0x04, 0x00, 0x1f, 0x00, 0x04,
+				0x00, 0x07, 0x23, 0x00, 0x0c, 0x27, 0x00, 0x02, 0x31, 0x00, 0x69, 0x64, 0x6e, 0x61, 0x6d, 0x65,
+				0x74, 0x61, 0x67, 0x73, 0xa0, 0x86, 0x01, 0x00, 0x09, 0x63, 0x61, 0x66, 0xc3, 0xa9, 0x20, 0x22,
+				0x78, 0x22, 0x04, 0x00, 0x1a, 0x00, 0x0c, 0x10, 0x00, 0x04, 0x01, 0x00, 0x04, 0x00, 0x00, 0x0b,
+				0x12, 0x00, 0x01, 0x78, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f,
+			},
+			`{"id": 100000, "name": "café \"x\"", "tags": ["x", true, null, 1.5]}`,
+			map[string]interface{}{
+				"id":   int64(100000),
+				"name": `café "x"`,
+				"tags": []interface{}{"x", true, nil, 1.5},
+			},
+		},
+		// Large array, with inlined 32 bit integers and a large object
+		{
+			[]byte{
+				0x03, 0x05, 0x00, 0x00, 0x00, 0x4d, 0x00, 0x00, 0x00, 0x08, 0x00, 0x28, 0x6b, 0xee, 0x09, 0x21,
+				0x00, 0x00, 0x00, 0x0a, 0x29, 0x00, 0x00, 0x00, 0x0b, 0x31, 0x00, 0x00, 0x00, 0x01, 0x39, 0x00,
+				0x00, 0x00, 0xfb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+				0xff, 0xff, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x08, 0x40, 0x01, 0x00, 0x00, 0x00, 0x14, 0x00,
+				0x00, 0x00, 0x13, 0x00, 0x00, 0x00, 0x01, 0x00, 0x04, 0x02, 0x00, 0x00, 0x00, 0x6b,
+			},
+			`[4000000000, -5, 18446744073709551615, 3.0, {"k": false}]`,
+			[]interface{}{uint64(4000000000), int64(-5), uint64(math.MaxUint64), 3.0, map[string]interface{}{"k": false}},
+		},
+		{[]byte{0x02, 0x00, 0x00, 0x04, 0x00}, `[]`, []interface{}{}},
+		{[]byte{0x0c, 0x02, 0x68, 0x69}, `"hi"`, "hi"},
+		{[]byte{0x04, 0x00}, `null`, nil},
+		{[]byte{}, `null`, nil},
+		{[]byte{0x09, 0x00, 0xe6, 0x8e, 0xe7, 0xfd, 0xff, 0xff, 0xff}, `-9000000000`, int64(-9000000000)},
+		{[]byte{0x0b, 0x40, 0x8c, 0xb5, 0x78, 0x1d, 0xaf, 0x15, 0x44}, `1e20`, 1e20},
+		{[]byte{0x05, 0xfe, 0xff}, `-2`, int64(-2)},
+		// Opaque values
+		{[]byte{0x0f, 0xf6, 0x04, 0x04, 0x02, 0x8c, 0x32}, `12.50`, json.Number("12.50")},
+		{
+			[]byte{0x0f, 0x0c, 0x08, 0x40, 0xe2, 0x01, 0x19, 0x76, 0x1f, 0x95, 0x19},
+			`"2015-01-15 23:24:25.123456"`,
+			"2015-01-15 23:24:25.123456",
+		},
+		{[]byte{0x0f, 0x0b, 0x08, 0xfc, 0xff, 0xff, 0x7c, 0xef, 0xff, 0xff, 0xff}, `"-01:02:03.000004"`, "-01:02:03.000004"},
+		{[]byte{0x0f, 0x0a, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1e, 0x95, 0x19}, `"2015-01-15"`, "2015-01-15"},
+		// TIME hours of 32 and over use bits beyond a DATETIME's hour
+		{[]byte{0x0f, 0x0b, 0x08, 0x00, 0x00, 0x00, 0xfb, 0x6e, 0x34, 0x00, 0x00}, `"838:59:59.000000"`, "838:59:59.000000"},
+		{[]byte{0x0f, 0x0b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x40, 0x06, 0x00, 0x00}, `"100:00:00.000000"`, "100:00:00.000000"},
+		{[]byte{0x0f, 0x0f, 0x02, 0x00, 0x01}, `"base64:type15:AAE="`, "base64:type15:AAE="},
+	}
+
+	for _, test := range tests {
+		document, err := decodeBinaryJSON(test.data)
+		checkErr(t, err)
+
+		assert.Equal(t, test.text, document.text)
+		assert.Equal(t, test.value, document.value, test.text)
+	}
+}
+
+func TestDecodeBadBinaryJSON(t *testing.T) {
+	for _, data := range [][]byte{
+		{0x0d},                                  // unknown type
+		{0x04, 0x07},                            // unknown literal
+		{0x04},                                  // literal cut short
+		{0x09, 0x01, 0x02},                      // integer cut short
+		{0x0c, 0x05, 'a'},                       // string cut short
+		{0x0c, 0x80, 0x80, 0x80, 0x80, 0x80, 1}, // size too long
+		{0x00, 0x01, 0x00, 0x40, 0x00},          // size past the end
+		{0x00, 0x05, 0x00, 0x06, 0x00, 0, 0},    // entries past the end
+		// value offset past the end of the array
+		{0x02, 0x01, 0x00, 0x07, 0x00, 0x0c, 0x09, 0x00},
+		// value offset back to the array itself
+		{0x02, 0x01, 0x00, 0x07, 0x00, 0x02, 0x00, 0x00},
+		// key offset into the entries
+		{0x00, 0x01, 0x00, 0x0c, 0x00, 0x00, 0x00, 0x01, 0x00, 0x04, 0x01, 0x00, 0x61},
+		nestedJSONArrays(JSON_DOCUMENT_MAX_DEPTH + 1),
+	} {
+		_, err := decodeBinaryJSON(data)
+		assert.Error(t, err, "%x", data)
+	}
+
+	document, err := decodeBinaryJSON(nestedJSONArrays(JSON_DOCUMENT_MAX_DEPTH))
+	checkErr(t, err)
+	assert.Equal(t, strings.Repeat("[", JSON_DOCUMENT_MAX_DEPTH) + strings.Repeat("]", JSON_DOCUMENT_MAX_DEPTH), document.text)
+}
+
+// A document of depth arrays, each holding the next and the last empty
+func nestedJSONArrays(depth int) []byte {
+	array := []byte{0x00, 0x00, 0x04, 0x00}
+
+	for i := 1; i < depth; i++ {
+		size := 7 + len(array)
+		array = append([]byte{0x01, 0x00, byte(size), byte(size >> 8), JSONB_TYPE_SMALL_ARRAY, 0x07, 0x00}, array...)
+	}
+
+	return append([]byte{JSONB_TYPE_SMALL_ARRAY}, array...)
+}
+
+func TestJsonColumn(t *testing.T) {
+	document := []byte{0x00, 0x01, 0x00, 0x0c, 0x00, 0x0b, 0x00, 0x01, 0x00, 0x05, 0x01, 0x00, 0x61}
+
+	tableMap := &TableMapEvent{
+		ColumnTypes: []byte{MYSQL_TYPE_JSON},
+		Metadata:    []*ColumnMetadata{{data: []byte{4}, metaType: PACK_SIZE_METADATA}},
+	}
+
+	r := bytes.NewReader(append([]byte{byte(len(document)), 0, 0, 0}, document...))
+	cell, err := DeserializeRowImageCell(r, tableMap, 0)
+	checkErr(t, err)
+
+	if json, ok := cell.(JsonRowImageCell); assert.True(t, ok) {
+		assert.Equal(t, `{"a": 1}`, json.String())
+		assert.Equal(t, map[string]interface{}{"a": int64(1)}, json.Value())
+	}
+
+	assert.Equal(t, 0, r.Len())
+}
@@ -0,0 +1,91 @@
+package binlog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+type LogLevel int
+
+const (
+	LOG_LEVEL_DEBUG LogLevel = iota
+	LOG_LEVEL_INFO
+	LOG_LEVEL_WARN
+	LOG_LEVEL_ERROR
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LOG_LEVEL_DEBUG:
+		return "DEBUG"
+	case LOG_LEVEL_INFO:
+		return "INFO"
+	case LOG_LEVEL_WARN:
+		return "WARN"
+	case LOG_LEVEL_ERROR:
+		return "ERROR"
+	}
+
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// A single piece of structured context attached to a log message,
+// e.g. the offset or table id of the event being decoded
+type LogField struct {
+	Key   string
+	Value interface{}
+}
+
+func Field(key string, value interface{}) LogField {
+	return LogField{Key: key, Value: value}
+}
+
+/*
+LOGGING
+=======
+
+Nothing is written anywhere unless a Logger is handed to the Binlog with
+WithLogger. The messages are the decoding traces that used to be printed
+straight to stdout, so they are almost all LOG_LEVEL_DEBUG. Wrap whatever
+logging library you already use in this interface, or use NewWriterLogger
+to get plain text lines:
+
+	binlog.OpenBinlog(path, binlog.WithLogger(binlog.NewWriterLogger(os.Stderr, binlog.LOG_LEVEL_DEBUG)))
+
+*/
+
+type Logger interface {
+	Log(level LogLevel, message string, fields ...LogField)
+}
+
+// Discards everything, used when no logger is configured
+type nopLogger struct{}
+
+func (nopLogger) Log(LogLevel, string, ...LogField) {}
+
+type writerLogger struct {
+	w       io.Writer
+	minimum LogLevel
+}
+
+// Returns a Logger writing one line per message at or above the minimum
+// level to w, formatted as: LEVEL message key=value key=value
+func NewWriterLogger(w io.Writer, minimum LogLevel) Logger {
+	return &writerLogger{w: w, minimum: minimum}
+}
+
+func (l *writerLogger) Log(level LogLevel, message string, fields ...LogField) {
+	if level < l.minimum {
+		return
+	}
+
+	line := new(strings.Builder)
+	fmt.Fprintf(line, "%v %v", level, message)
+
+	for _, f := range fields {
+		fmt.Fprintf(line, " %v=%v", f.Key, f.Value)
+	}
+
+	fmt.Fprintln(l.w, line.String())
+}
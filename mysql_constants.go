@@ -1,4 +1,4 @@
-package main
+package binlog
 
 // These offset constants are based on v4 events
 // sadly, golang doesn't support constant arrays (because of slices I think)
@@ -15,6 +15,20 @@ const (
 	EVENT_EXTRA_OFFSET           = 19
 )
 
+// Size of the v4 common event header. The format description event can
+// declare a longer one, in which case the extra bytes are skipped.
+const EVENT_HEADER_LENGTH = 19
+
+// Checksum algorithms from the end of the format description event
+const (
+	BINLOG_CHECKSUM_ALG_OFF   byte = 0
+	BINLOG_CHECKSUM_ALG_CRC32 byte = 1
+	BINLOG_CHECKSUM_ALG_UNDEF byte = 255
+)
+
+// Size of the checksum footer when the algorithm is CRC32
+const BINLOG_CHECKSUM_LENGTH = 4
+
 const (
 	UNKOWN_EVENT             byte = iota
 	START_EVENT_V3
@@ -77,6 +91,8 @@ const (
 	MYSQL_TYPE_TIME_V2
 )
 
+const MYSQL_TYPE_JSON byte = 245 // MySQL 5.7.8+
+
 const (
 	MYSQL_TYPE_NEWDECIMAL  byte = 246 + iota
 	MYSQL_TYPE_ENUM                          // Does not appear in binlog
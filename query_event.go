@@ -0,0 +1,389 @@
+package binlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Status variable codes found in a QUERY_EVENT
+const (
+	Q_FLAGS2_CODE                     byte = iota
+	Q_SQL_MODE_CODE
+	Q_CATALOG_CODE
+	Q_AUTO_INCREMENT
+	Q_CHARSET_CODE
+	Q_TIME_ZONE_CODE
+	Q_CATALOG_NZ_CODE
+	Q_LC_TIME_NAMES_CODE
+	Q_CHARSET_DATABASE_CODE
+	Q_TABLE_MAP_FOR_UPDATE_CODE
+	Q_MASTER_DATA_WRITTEN_CODE
+	Q_INVOKER
+	Q_UPDATED_DB_NAMES
+	Q_MICROSECONDS
+	Q_COMMIT_TS
+	Q_COMMIT_TS2
+	Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP
+	Q_DDL_LOGGED_WITH_XID
+	Q_DEFAULT_COLLATION_FOR_UTF8MB4
+	Q_SQL_REQUIRE_PRIMARY_KEY
+	Q_DEFAULT_TABLE_ENCRYPTION
+)
+
+// Q_UPDATED_DB_NAMES count meaning too many databases were touched to list
+const OVER_MAX_DBS_IN_EVENT_MTS = 254
+
+// Bits of QueryStatusVars.Flags2
+const (
+	OPTION_AUTO_IS_NULL          uint32 = 0x00004000
+	OPTION_NOT_AUTOCOMMIT        uint32 = 0x00080000
+	OPTION_NO_FOREIGN_KEY_CHECKS uint32 = 0x04000000
+	OPTION_RELAXED_UNIQUE_CHECKS uint32 = 0x08000000
+)
+
+type QueryEvent struct {
+	ThreadId      uint32
+	ExecutionTime uint32
+	ErrorCode     uint16
+	StatusVars    QueryStatusVars
+	Database      string
+	Query         string
+}
+
+type QueryCharset struct {
+	Client     uint16
+	Connection uint16
+	Server     uint16
+}
+
+// Session state the statement ran with. Variables the server did not
+// write are left nil (or empty for strings and slices).
+type QueryStatusVars struct {
+	Flags2                       *uint32
+	SqlMode                      *uint64
+	Catalog                      string
+	AutoIncrementIncrement       *uint16
+	AutoIncrementOffset          *uint16
+	Charset                      *QueryCharset
+	TimeZone                     string
+	LcTimeNames                  *uint16
+	CharsetDatabase              *uint16
+	TableMapForUpdate            *uint64
+	MasterDataWritten            *uint32
+	InvokerUser                  string
+	InvokerHost                  string
+	UpdatedDbNames               []string // nil with more than OVER_MAX_DBS_IN_EVENT_MTS
+	Microseconds                 *uint32
+	ExplicitDefaultsForTimestamp *bool
+	DdlXid                       *uint64
+	DefaultCollationForUtf8mb4   *uint16
+	SqlRequirePrimaryKey         *uint8
+	DefaultTableEncryption       *uint8
+}
+
+type QueryEventDeserializer struct {}
+
+/*
+QUERY EVENT DATA
+================
+
+Let:
+S = status vars length
+D = database name length
+P = post-header length from the format description (13 for v4)
+
+Fixed (post-header):
+4 bytes = thread id
+4 bytes = execution time in seconds
+1 byte  = database name length
+2 bytes = error code
+2 bytes = status vars length (v4 only)
+P - 13 bytes = anything newer servers add (skip)
+
+Variable:
+S bytes   = status vars, each a 1 byte code followed by its value
+D+1 bytes = database name (null terminated)
+rest      = query text
+
+Status vars have to be written in increasing order of code, so an
+unknown code means everything after it is unknown as well and the
+rest of the block is skipped, same as MySQL does.
+
+*/
+
+func (d *QueryEventDeserializer) Deserialize(b *Binlog, reader io.ReadSeeker, header *EventHeader) (EventData, error) {
+	e := new(QueryEvent)
+
+	postHeaderLength := int(b.formatDescription.PostHeaderLength(header.Type))
+
+	var err error
+
+	e.ThreadId, err = ReadUint32(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	e.ExecutionTime, err = ReadUint32(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	databaseLength, err := ReadUint8(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	e.ErrorCode, err = ReadUint16(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	statusVarsLength := uint16(0)
+	read := 4 + 4 + 1 + 2
+
+	if postHeaderLength >= read + 2 {
+		statusVarsLength, err = ReadUint16(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		read += 2
+	}
+
+	if postHeaderLength > read {
+		if _, err = reader.Seek(int64(postHeaderLength - read), 1); err != nil {
+			return nil, err
+		}
+	}
+
+	statusVars, err := ReadBytes(reader, int(statusVarsLength))
+	if err != nil {
+		return nil, err
+	}
+
+	if err = e.StatusVars.deserialize(bytes.NewReader(statusVars)); err != nil {
+		return nil, fmt.Errorf("status vars: %v", err)
+	}
+
+	database, err := ReadBytes(reader, int(databaseLength) + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Database = string(database[:databaseLength])
+
+	query, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Query = string(query)
+
+	b.logger.Log(LOG_LEVEL_DEBUG, "Query event",
+		Field("thread_id", e.ThreadId),
+		Field("database", e.Database),
+		Field("error_code", e.ErrorCode),
+		Field("query", e.Query),
+	)
+
+	return e, nil
+}
+
+func readLengthPrefixedString(r io.Reader) (string, error) {
+	length, err := ReadUint8(r)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := ReadBytes(r, int(length))
+	return string(b), err
+}
+
+func (v *QueryStatusVars) deserialize(r *bytes.Reader) error {
+	for r.Len() > 0 {
+		code, err := ReadByte(r)
+		if err != nil {
+			return err
+		}
+
+		switch code {
+		case Q_FLAGS2_CODE:
+			flags, err := ReadUint32(r)
+			if err != nil {
+				return err
+			}
+
+			v.Flags2 = &flags
+
+		case Q_SQL_MODE_CODE:
+			mode, err := ReadUint64(r)
+			if err != nil {
+				return err
+			}
+
+			v.SqlMode = &mode
+
+		case Q_CATALOG_CODE:
+			// Only written by 5.0.0 - 5.0.3, with a trailing null
+			if v.Catalog, err = readLengthPrefixedString(r); err != nil {
+				return err
+			}
+
+			if _, err = ReadByte(r); err != nil {
+				return err
+			}
+
+		case Q_AUTO_INCREMENT:
+			increment, err := ReadUint16(r)
+			if err != nil {
+				return err
+			}
+
+			offset, err := ReadUint16(r)
+			if err != nil {
+				return err
+			}
+
+			v.AutoIncrementIncrement = &increment
+			v.AutoIncrementOffset = &offset
+
+		case Q_CHARSET_CODE:
+			charset := new(QueryCharset)
+
+			for _, field := range []*uint16{&charset.Client, &charset.Connection, &charset.Server} {
+				if *field, err = ReadUint16(r); err != nil {
+					return err
+				}
+			}
+
+			v.Charset = charset
+
+		case Q_TIME_ZONE_CODE:
+			if v.TimeZone, err = readLengthPrefixedString(r); err != nil {
+				return err
+			}
+
+		case Q_CATALOG_NZ_CODE:
+			if v.Catalog, err = readLengthPrefixedString(r); err != nil {
+				return err
+			}
+
+		case Q_LC_TIME_NAMES_CODE:
+			names, err := ReadUint16(r)
+			if err != nil {
+				return err
+			}
+
+			v.LcTimeNames = &names
+
+		case Q_CHARSET_DATABASE_CODE:
+			charset, err := ReadUint16(r)
+			if err != nil {
+				return err
+			}
+
+			v.CharsetDatabase = &charset
+
+		case Q_TABLE_MAP_FOR_UPDATE_CODE:
+			tables, err := ReadUint64(r)
+			if err != nil {
+				return err
+			}
+
+			v.TableMapForUpdate = &tables
+
+		case Q_MASTER_DATA_WRITTEN_CODE:
+			written, err := ReadUint32(r)
+			if err != nil {
+				return err
+			}
+
+			v.MasterDataWritten = &written
+
+		case Q_INVOKER:
+			if v.InvokerUser, err = readLengthPrefixedString(r); err != nil {
+				return err
+			}
+
+			if v.InvokerHost, err = readLengthPrefixedString(r); err != nil {
+				return err
+			}
+
+		case Q_UPDATED_DB_NAMES:
+			count, err := ReadUint8(r)
+			if err != nil {
+				return err
+			}
+
+			if count == OVER_MAX_DBS_IN_EVENT_MTS {
+				break
+			}
+
+			v.UpdatedDbNames = make([]string, count)
+
+			for i := range v.UpdatedDbNames {
+				if v.UpdatedDbNames[i], err = ReadNullTerminatedString(r); err != nil {
+					return err
+				}
+			}
+
+		case Q_MICROSECONDS:
+			b, err := ReadBytes(r, 3)
+			if err != nil {
+				return err
+			}
+
+			microseconds := uint32(b[0]) | uint32(b[1]) << 8 | uint32(b[2]) << 16
+			v.Microseconds = &microseconds
+
+		case Q_EXPLICIT_DEFAULTS_FOR_TIMESTAMP:
+			explicit, err := ReadUint8(r)
+			if err != nil {
+				return err
+			}
+
+			isExplicit := explicit != 0
+			v.ExplicitDefaultsForTimestamp = &isExplicit
+
+		case Q_DDL_LOGGED_WITH_XID:
+			xid, err := ReadUint64(r)
+			if err != nil {
+				return err
+			}
+
+			v.DdlXid = &xid
+
+		case Q_DEFAULT_COLLATION_FOR_UTF8MB4:
+			collation, err := ReadUint16(r)
+			if err != nil {
+				return err
+			}
+
+			v.DefaultCollationForUtf8mb4 = &collation
+
+		case Q_SQL_REQUIRE_PRIMARY_KEY:
+			require, err := ReadUint8(r)
+			if err != nil {
+				return err
+			}
+
+			v.SqlRequirePrimaryKey = &require
+
+		case Q_DEFAULT_TABLE_ENCRYPTION:
+			encryption, err := ReadUint8(r)
+			if err != nil {
+				return err
+			}
+
+			v.DefaultTableEncryption = &encryption
+
+		default:
+			// Unknown (or never written, like Q_COMMIT_TS), so we can't
+			// know how long it is and have to stop here
+			return nil
+		}
+	}
+
+	return nil
+}
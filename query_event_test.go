@@ -0,0 +1,88 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func queryTestEvent(database, query string, statusVars []byte) testEvent {
+	payload := new(bytes.Buffer)
+
+	binary.Write(payload, binary.LittleEndian, uint32(42)) // thread id
+	binary.Write(payload, binary.LittleEndian, uint32(3))  // execution time
+	payload.WriteByte(byte(len(database)))
+	binary.Write(payload, binary.LittleEndian, uint16(0)) // error code
+	binary.Write(payload, binary.LittleEndian, uint16(len(statusVars)))
+	payload.Write(statusVars)
+	payload.WriteString(database)
+	payload.WriteByte(NUL)
+	payload.WriteString(query)
+
+	return testEvent{QUERY_EVENT, payload.Bytes()}
+}
+
+func TestQueryEvent(t *testing.T) {
+	statusVars := []byte{
+		Q_FLAGS2_CODE, 0x00, 0x00, 0x00, 0x04,
+		Q_SQL_MODE_CODE, 0x00, 0x00, 0x20, 0x40, 0x00, 0x00, 0x00, 0x00,
+		Q_CATALOG_NZ_CODE, 3, 's', 't', 'd',
+		Q_AUTO_INCREMENT, 0x02, 0x00, 0x01, 0x00,
+		Q_CHARSET_CODE, 0x21, 0x00, 0x21, 0x00, 0x08, 0x00,
+		Q_TIME_ZONE_CODE, 6, 'S', 'Y', 'S', 'T', 'E', 'M',
+		Q_INVOKER, 4, 'r', 'o', 'o', 't', 9, 'l', 'o', 'c', 'a', 'l', 'h', 'o', 's', 't',
+		Q_UPDATED_DB_NAMES, 2, 'a', NUL, 'b', 'c', NUL,
+		Q_MICROSECONDS, 0x40, 0x42, 0x0f,
+		Q_DDL_LOGGED_WITH_XID, 0x07, 0, 0, 0, 0, 0, 0, 0,
+		Q_DEFAULT_COLLATION_FOR_UTF8MB4, 0xff, 0x00,
+		// unknown, stops decoding
+		0xc8, 0xde, 0xad,
+	}
+
+	b := openTestBinlog(t, makeChecksummedTestBinlog(queryTestEvent("shop", "CREATE TABLE t (id INT)", statusVars)))
+
+	event, err := b.NextEvent()
+	checkErr(t, err)
+
+	e, ok := event.Data().(*QueryEvent)
+	if !assert.True(t, ok, "expected *QueryEvent, got %T", event.Data()) {
+		return
+	}
+
+	assert.Equal(t, uint32(42), e.ThreadId)
+	assert.Equal(t, uint32(3), e.ExecutionTime)
+	assert.Equal(t, uint16(0), e.ErrorCode)
+	assert.Equal(t, "shop", e.Database)
+	assert.Equal(t, "CREATE TABLE t (id INT)", e.Query)
+
+	v := e.StatusVars
+	assert.Equal(t, OPTION_NO_FOREIGN_KEY_CHECKS, *v.Flags2)
+	assert.Equal(t, uint64(0x40200000), *v.SqlMode)
+	assert.Equal(t, "std", v.Catalog)
+	assert.Equal(t, uint16(2), *v.AutoIncrementIncrement)
+	assert.Equal(t, uint16(1), *v.AutoIncrementOffset)
+	assert.Equal(t, QueryCharset{Client: 33, Connection: 33, Server: 8}, *v.Charset)
+	assert.Equal(t, "SYSTEM", v.TimeZone)
+	assert.Equal(t, "root", v.InvokerUser)
+	assert.Equal(t, "localhost", v.InvokerHost)
+	assert.Equal(t, []string{"a", "bc"}, v.UpdatedDbNames)
+	assert.Equal(t, uint32(1000000), *v.Microseconds)
+	assert.Equal(t, uint64(7), *v.DdlXid)
+	assert.Equal(t, uint16(255), *v.DefaultCollationForUtf8mb4)
+	assert.Nil(t, v.LcTimeNames)
+	assert.Nil(t, v.SqlRequirePrimaryKey)
+}
+
+func TestQueryEventWithoutStatusVars(t *testing.T) {
+	b := openTestBinlog(t, makeTestBinlog(queryTestEvent("", "BEGIN", nil)))
+
+	event, err := b.NextEvent()
+	checkErr(t, err)
+
+	e := event.Data().(*QueryEvent)
+	assert.Equal(t, "", e.Database)
+	assert.Equal(t, "BEGIN", e.Query)
+	assert.Nil(t, e.StatusVars.Flags2)
+}
@@ -0,0 +1,52 @@
+package binlog
+
+import (
+	"io"
+)
+
+// Written as the last event of a log when the server moves on to a new file
+type RotateEvent struct {
+	Position uint64
+	NextFile string
+}
+
+type RotateEventDeserializer struct {}
+
+/*
+ROTATE EVENT DATA
+=================
+
+Fixed (post-header, v4 only):
+8 bytes = position of the first event in the next file
+
+Variable:
+rest    = name of the next file (not null terminated)
+
+*/
+
+func (d *RotateEventDeserializer) Deserialize(b *Binlog, reader io.ReadSeeker, header *EventHeader) (EventData, error) {
+	e := &RotateEvent{Position: 4}
+
+	if b.formatDescription.PostHeaderLength(header.Type) >= 8 {
+		var err error
+
+		e.Position, err = ReadUint64(reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	name, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	e.NextFile = string(name)
+
+	b.logger.Log(LOG_LEVEL_DEBUG, "Rotate event",
+		Field("next_file", e.NextFile),
+		Field("position", e.Position),
+	)
+
+	return e, nil
+}
@@ -1,10 +1,13 @@
-package main
+package binlog
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
-	"log"
+	"math"
+	"math/big"
+	"strings"
 	"time"
 )
 
@@ -14,166 +17,607 @@ type RowImage []RowImageCell
 type RowImageCell interface {}
 
 type NullRowImageCell                byte
-type NumberRowImageCell              uint64
+type NumberRowImageCell              struct {
+	mysqlType byte
+	value     uint64 // as stored, zero extended
+	unsigned  bool
+}
 type FloatingPointNumberRowImageCell float32
-type ComplexNumberRowImageCell       complex64
+type DoubleRowImageCell              float64
 type BlobRowImageCell                []byte
 type StringRowImageCell              struct {
 	mysqlType byte
 	value     string
 }
+type EnumRowImageCell                uint16 // 1 based index, 0 for ''
+type SetRowImageCell                 uint64 // bit i set for member i
+type BitRowImageCell                 struct {
+	bits  int
+	value []byte // big endian
+}
+type JsonRowImageCell                struct {
+	value interface{}
+	text  string
+}
+type GeometryRowImageCell            struct {
+	srid uint32
+	wkb  []byte
+}
+type DecimalRowImageCell             struct {
+	unscaled *big.Int
+	scale    int
+}
 type DurationRowImageCell            time.Duration
 type TimeRowImageCell                struct {
 	mysqlType byte
 	value     time.Time
 }
+type ZeroInDateRowImageCell          struct {
+	mysqlType byte
+	value     MysqlDatetime
+}
 
 func NewNullRowImageCell(mysqlType byte) NullRowImageCell {
 	return NullRowImageCell(mysqlType)
 }
 
-func DeserializeRowImageCell(r io.Reader, tableMap *TableMapEvent, columnIndex int) RowImageCell {
+// value is the integer as stored in the row, in the width of mysqlType
+func NewNumberRowImageCell(mysqlType byte, value uint64, unsigned bool) NumberRowImageCell {
+	return NumberRowImageCell{
+		mysqlType: mysqlType,
+		value:     value,
+		unsigned:  unsigned,
+	}
+}
+
+func (c NumberRowImageCell) Type() byte {
+	return c.mysqlType
+}
+
+// Reports whether the column is UNSIGNED. Columns whose signedness isn't
+// known (see TableMapEvent.IsUnsigned) are taken to be signed.
+func (c NumberRowImageCell) Unsigned() bool {
+	return c.unsigned
+}
+
+// The value read as a signed integer of the column's width
+func (c NumberRowImageCell) Int64() int64 {
+	bits := uint(64)
+
+	switch c.mysqlType {
+	case MYSQL_TYPE_TINY:
+		bits = 8
+	case MYSQL_TYPE_SHORT:
+		bits = 16
+	case MYSQL_TYPE_INT24:
+		bits = 24
+	case MYSQL_TYPE_LONG:
+		bits = 32
+	}
+
+	// Shift the sign bit to the top and back down to extend it
+	return int64(c.value << (64 - bits)) >> (64 - bits)
+}
+
+// The value read as an unsigned integer of the column's width
+func (c NumberRowImageCell) Uint64() uint64 {
+	return c.value
+}
+
+// Returns an int64, or a uint64 for UNSIGNED columns
+func (c NumberRowImageCell) Value() interface{} {
+	if c.unsigned {
+		return c.Uint64()
+	}
+
+	return c.Int64()
+}
+
+func (c NumberRowImageCell) String() string {
+	return fmt.Sprint(c.Value())
+}
+
+func NewStringRowImageCell(mysqlType byte, value string) StringRowImageCell {
+	return StringRowImageCell{
+		mysqlType: mysqlType,
+		value:     value,
+	}
+}
+
+// VARCHAR, STRING or VAR_STRING
+func (c StringRowImageCell) Type() byte {
+	return c.mysqlType
+}
+
+// The value's bytes, in the column's character set
+func (c StringRowImageCell) String() string {
+	return c.value
+}
+
+// Returns the member the index refers to, given the column's values (see
+// TableMapOptionalMetadata.EnumValues). Index 0 and indexes out of
+// range give "", which is what MySQL stores for invalid values.
+func (c EnumRowImageCell) Label(values []string) string {
+	if c == 0 || int(c) > len(values) {
+		return ""
+	}
+
+	return values[c - 1]
+}
+
+// Returns the members the bitmask holds, given the column's values (see
+// TableMapOptionalMetadata.SetValues)
+func (c SetRowImageCell) Members(values []string) []string {
+	members := []string{}
+
+	for i, value := range values {
+		if i < 64 && uint64(c) & (1 << uint(i)) != 0 {
+			members = append(members, value)
+		}
+	}
+
+	return members
+}
+
+// value holds the bits big endian, in (bits + 7) / 8 bytes
+func NewBitRowImageCell(bits int, value []byte) BitRowImageCell {
+	return BitRowImageCell{
+		bits:  bits,
+		value: value,
+	}
+}
+
+// The n of the column's BIT(n)
+func (c BitRowImageCell) Bits() int {
+	return c.bits
+}
+
+// The value as stored, big endian
+func (c BitRowImageCell) Bytes() []byte {
+	return c.value
+}
+
+func (c BitRowImageCell) Uint64() uint64 {
+	v := uint64(0)
+
+	for _, b := range c.value {
+		v = v << 8 | uint64(b)
+	}
+
+	return v
+}
+
+// Formats the value as MySQL's b'...' literal, with all n bits
+func (c BitRowImageCell) String() string {
+	return fmt.Sprintf("b'%0*b'", c.bits, c.Uint64())
+}
+
+// The document in its Go form: nil, bool, int64, uint64, float64,
+// string, json.Number (for DECIMAL), []interface{} or
+// map[string]interface{}. Dates and times are strings, formatted the way
+// MySQL prints them.
+func (c JsonRowImageCell) Value() interface{} {
+	return c.value
+}
+
+// The document as MySQL prints it, keeping the order the keys were
+// stored in
+func (c JsonRowImageCell) String() string {
+	return c.text
+}
+
+// The spatial reference system the geometry is in, 0 if none
+func (c GeometryRowImageCell) SRID() uint32 {
+	return c.srid
+}
+
+// The geometry in Well-Known Binary
+func (c GeometryRowImageCell) WKB() []byte {
+	return c.wkb
+}
+
+// The geometry in Well-Known Text, e.g. POINT(1 2)
+func (c GeometryRowImageCell) WKT() (string, error) {
+	return WKBToWKT(c.wkb)
+}
+
+func (c GeometryRowImageCell) String() string {
+	wkt, err := c.WKT()
+	if err != nil {
+		return fmt.Sprintf("invalid geometry (%v)", err)
+	}
+
+	return wkt
+}
+
+// An exact decimal: unscaled * 10^-scale
+func NewDecimalRowImageCell(unscaled *big.Int, scale int) DecimalRowImageCell {
+	return DecimalRowImageCell{
+		unscaled: new(big.Int).Set(unscaled),
+		scale:    scale,
+	}
+}
+
+// The value without its decimal point, e.g. -12345 for -123.45
+func (c DecimalRowImageCell) Unscaled() *big.Int {
+	return new(big.Int).Set(c.unscaled)
+}
+
+// The number of digits after the decimal point
+func (c DecimalRowImageCell) Scale() int {
+	return c.scale
+}
+
+// Formats the value with exactly Scale() decimals, as MySQL does
+func (c DecimalRowImageCell) String() string {
+	digits := new(big.Int).Abs(c.unscaled).String()
+
+	if len(digits) <= c.scale {
+		digits = strings.Repeat("0", c.scale - len(digits) + 1) + digits
+	}
+
+	sign := ""
+	if c.unscaled.Sign() < 0 {
+		sign = "-"
+	}
+
+	if c.scale == 0 {
+		return sign + digits
+	}
+
+	point := len(digits) - c.scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// The column type, e.g. MYSQL_TYPE_DATETIME or MYSQL_TYPE_TIMESTAMP_V2
+func (c TimeRowImageCell) Type() byte {
+	return c.mysqlType
+}
+
+// The value as a time.Time in UTC. Zero dates (0000-00-00) give the zero
+// time.Time.
+func (c TimeRowImageCell) Time() time.Time {
+	return c.value
+}
+
+// DATE and DATETIME values with a zero month or day but not all zero,
+// which have no time.Time, are kept field by field
+func newDatetimeRowImageCell(mysqlType byte, d MysqlDatetime) RowImageCell {
+	if d.IsZero() || !d.ZeroInDate() {
+		v, _ := d.Time()
+		return TimeRowImageCell{mysqlType: mysqlType, value: v}
+	}
+
+	return ZeroInDateRowImageCell{mysqlType: mysqlType, value: d}
+}
+
+func (c ZeroInDateRowImageCell) Type() byte {
+	return c.mysqlType
+}
+
+func (c ZeroInDateRowImageCell) Datetime() MysqlDatetime {
+	return c.value
+}
+
+// Formats DATE values without a time, e.g. 2014-00-00
+func (c ZeroInDateRowImageCell) String() string {
+	if c.mysqlType == MYSQL_TYPE_DATE {
+		return c.value.String()[:len("0000-00-00")]
+	}
+
+	return c.value.String()
+}
+
+// Reads a value prefixed with its length, which takes 1 byte if the
+// column holds at most 255 bytes and 2 otherwise
+func readLengthPrefixedValue(r io.Reader, maxLength int) ([]byte, error) {
+	prefixSize := 1
+	if maxLength > 255 {
+		prefixSize = 2
+	}
+
+	length, err := ReadUintN(r, prefixSize)
+	if err != nil {
+		return nil, err
+	}
+
+	if int(length) > maxLength {
+		return nil, fmt.Errorf("value length %v exceeds the column's %v", length, maxLength)
+	}
+
+	return ReadBytes(r, int(length))
+}
+
+// Reads a value prefixed with its length, the length taking the
+// metadata's pack size in bytes
+func readBlobValue(r io.Reader, metadata *ColumnMetadata) ([]byte, error) {
+	packSize, err := metadata.PackSize()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := ReadUintN(r, int(packSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return ReadBytes(r, int(length))
+}
+
+func DeserializeRowImageCell(r io.Reader, tableMap *TableMapEvent, columnIndex int) (RowImageCell, error) {
 	mysqlType := tableMap.ColumnTypes[columnIndex]
 
 	switch mysqlType {
 	// impossible cases
 	case MYSQL_TYPE_ENUM, MYSQL_TYPE_NEWDATE, MYSQL_TYPE_SET,
 	  MYSQL_TYPE_TINY_BLOB, MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB:
-		log.Fatal("Impossible type found in binlog!")
+		return nil, fmt.Errorf("impossible type found in binlog: %v", mysqlType)
 
 	case MYSQL_TYPE_TINY:
 		v, err := ReadUint8(r)
-		fatalErr(err)
+		if err != nil {
+			return nil, err
+		}
 
-		return NumberRowImageCell(v)
+		return NewNumberRowImageCell(mysqlType, uint64(v), tableMap.IsUnsigned(columnIndex)), nil
 
 	case MYSQL_TYPE_SHORT:
 		v, err := ReadUint16(r)
-		fatalErr(err)
+		if err != nil {
+			return nil, err
+		}
 
-		return NumberRowImageCell(v)
+		return NewNumberRowImageCell(mysqlType, uint64(v), tableMap.IsUnsigned(columnIndex)), nil
 
 	case MYSQL_TYPE_INT24:
 		b, err := ReadBytes(r, 3)
-		fatalErr(err)
+		if err != nil {
+			return nil, err
+		}
 
-		v, err := uint32FromBuffer(bytes.NewBuffer(b))
-		fatalErr(err)
+		v, err := uint32FromBuffer(bytes.NewBuffer(append(b, NUL)))
+		if err != nil {
+			return nil, err
+		}
 
-		return NumberRowImageCell(v)
+		return NewNumberRowImageCell(mysqlType, uint64(v), tableMap.IsUnsigned(columnIndex)), nil
 
 	case MYSQL_TYPE_LONG:
 		v, err := ReadUint32(r)
-		fatalErr(err)
+		if err != nil {
+			return nil, err
+		}
 
-		return NumberRowImageCell(v)
+		return NewNumberRowImageCell(mysqlType, uint64(v), tableMap.IsUnsigned(columnIndex)), nil
 
 	case MYSQL_TYPE_LONGLONG:
 		v, err := ReadUint64(r)
-		fatalErr(err)
+		if err != nil {
+			return nil, err
+		}
 
-		return NumberRowImageCell(v)
+		return NewNumberRowImageCell(mysqlType, uint64(v), tableMap.IsUnsigned(columnIndex)), nil
 
-	case MYSQL_TYPE_FLOAT:
-		var v float32
-		b, err := ReadBytes(r, 4)
-		fatalErr(err)
+	case MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE:
+		packSize, err := tableMap.Metadata[columnIndex].PackSize()
+		if err != nil {
+			return nil, err
+		}
 
-		fatalErr(readFromBinaryBuffer(bytes.NewBuffer(b), &v))
+		// FLOAT is 4 bytes and DOUBLE 8, but the metadata has the final say
+		switch packSize {
+		case 4:
+			v, err := ReadUint32(r)
+			if err != nil {
+				return nil, err
+			}
 
-		return FloatingPointNumberRowImageCell(v)
+			return FloatingPointNumberRowImageCell(math.Float32frombits(v)), nil
 
-	case MYSQL_TYPE_DOUBLE:
-		// Not sure if C doubles convert to Go complex64 properly
-		var v complex64
-		b, err := ReadBytes(r, 8)
-		fatalErr(err)
+		case 8:
+			v, err := ReadUint64(r)
+			if err != nil {
+				return nil, err
+			}
 
-		fatalErr(readFromBinaryBuffer(bytes.NewBuffer(b), &v))
+			return DoubleRowImageCell(math.Float64frombits(v)), nil
+		}
 
-		return ComplexNumberRowImageCell(v)
+		return nil, fmt.Errorf("floating point column with pack size %v", packSize)
 
 	case MYSQL_TYPE_NULL:
-		return NewNullRowImageCell(mysqlType)
+		return NewNullRowImageCell(mysqlType), nil
 
-	case MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_DATE, MYSQL_TYPE_TIME, MYSQL_TYPE_DATETIME:
-		log.Fatal("time fields disabled")
+	case MYSQL_TYPE_TIME:
+		v, err := ReadTimeV1(r)
+		if err != nil {
+			return nil, err
+		}
 
-	case MYSQL_TYPE_TIME_V2:
-		v, err := ReadTimeV2(r)
-		fatalErr(err)
+		return DurationRowImageCell(v), nil
+
+	case MYSQL_TYPE_TIMESTAMP:
+		v, err := ReadTimestampV1(r)
+		if err != nil {
+			return nil, err
+		}
 
-		return DurationRowImageCell(v)
+		return TimeRowImageCell{
+			mysqlType: mysqlType,
+			value:     v,
+		}, nil
 
-	case MYSQL_TYPE_DATETIME_V2, MYSQL_TYPE_TIMESTAMP_V2:
-		var fn func(io.Reader, *ColumnMetadata) (time.Time, error)
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_DATETIME:
+		var fn func(io.Reader) (MysqlDatetime, error)
 
-		if mysqlType == MYSQL_TYPE_DATETIME_V2 {
-			fn = ReadDatetimeV2
-			fmt.Println("datetime")
+		if mysqlType == MYSQL_TYPE_DATE {
+			fn = readDateParts
 		} else {
-			fn = ReadTimestampV2
-			fmt.Println("timestamp")
+			fn = readDatetimeV1Parts
+		}
+
+		d, err := fn(r)
+		if err != nil {
+			return nil, err
+		}
+
+		return newDatetimeRowImageCell(mysqlType, d), nil
+
+	case MYSQL_TYPE_TIME_V2:
+		v, err := ReadTimeV2(r, tableMap.Metadata[columnIndex])
+		if err != nil {
+			return nil, err
+		}
+
+		return DurationRowImageCell(v), nil
+
+	case MYSQL_TYPE_DATETIME_V2:
+		d, err := readDatetimeV2Parts(r, tableMap.Metadata[columnIndex])
+		if err != nil {
+			return nil, err
 		}
 
-		v, err := fn(r, tableMap.Metadata[columnIndex])
-		fatalErr(err)
+		return newDatetimeRowImageCell(mysqlType, d), nil
+
+	case MYSQL_TYPE_TIMESTAMP_V2:
+		v, err := ReadTimestampV2(r, tableMap.Metadata[columnIndex])
+		if err != nil {
+			return nil, err
+		}
 
 		return TimeRowImageCell{
 			mysqlType: mysqlType,
 			value:     v,
-		}
+		}, nil
 
 	case MYSQL_TYPE_YEAR:
 		v, err := ReadUint8(r)
-		fatalErr(err)
+		if err != nil {
+			return nil, err
+		}
 
-		return NumberRowImageCell(1900 + uint64(v))
+		return NewNumberRowImageCell(mysqlType, 1900 + uint64(v), true), nil
 
 	case MYSQL_TYPE_BIT:
-		log.Fatal("BIT currently disabled")
-		// metadata := tableMap.Metadata[columnIndex]
+		bits, err := tableMap.Metadata[columnIndex].BitCount()
+		if err != nil {
+			return nil, err
+		}
+
+		if bits < 1 || bits > 64 {
+			return nil, fmt.Errorf("invalid BIT length %v", bits)
+		}
+
+		b, err := ReadBytes(r, (bits + 7) / 8)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewBitRowImageCell(bits, b), nil
 
 	case MYSQL_TYPE_NEWDECIMAL:
-		// Not currently supported, may never be supported
-		log.Fatal("NEWDECIMAL values are not supported.")
+		unscaled, scale, err := ReadNewDecimal(r, tableMap.Metadata[columnIndex])
+		if err != nil {
+			return nil, err
+		}
+
+		return DecimalRowImageCell{
+			unscaled: unscaled,
+			scale:    scale,
+		}, nil
 
 	case MYSQL_TYPE_VARCHAR:
-		log.Fatal("VARCHAR currently disabled")
+		maxLength, err := tableMap.Metadata[columnIndex].MaxLength()
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := readLengthPrefixedValue(r, int(maxLength))
+		if err != nil {
+			return nil, err
+		}
+
+		return NewStringRowImageCell(mysqlType, string(value)), nil
 
 	case MYSQL_TYPE_STRING, MYSQL_TYPE_VAR_STRING:
-		log.Fatal("STRING/VAR_STRING currently disabled")
+		realType, length, err := tableMap.Metadata[columnIndex].RealTypeAndLength()
+		if err != nil {
+			return nil, err
+		}
 
-		/*
-		metadata := tableMap.Metadata[columnIndex]
-		lengthBytes, err := ReadBytes(r, metadata.PackSize())
-		fatalErr(err)
+		switch realType {
+		case MYSQL_TYPE_ENUM:
+			if length != 1 && length != 2 {
+				return nil, fmt.Errorf("invalid ENUM index size %v", length)
+			}
 
-		var length uint32
-		fatalErr(readFromBinaryBuffer(bytes.NewBuffer(lengthBytes), &length))
+			v, err := ReadUintN(r, int(length))
+			if err != nil {
+				return nil, err
+			}
 
-		b, err := ReadBytes(r, length)
-		fatalErr(err)
+			return EnumRowImageCell(v), nil
 
-		return StringRowImageCell{
-			mysqlType: metadata.RealType(),
-			value:     string(b),
+		case MYSQL_TYPE_SET:
+			v, err := ReadUintN(r, int(length))
+			if err != nil {
+				return nil, err
+			}
+
+			return SetRowImageCell(v), nil
+		}
+
+		value, err := readLengthPrefixedValue(r, int(length))
+		if err != nil {
+			return nil, err
 		}
-		*/
+
+		return NewStringRowImageCell(mysqlType, string(value)), nil
 
 	case MYSQL_TYPE_BLOB:
-		metadata := tableMap.Metadata[columnIndex]
-		b, err := ReadBytes(r, int(metadata.PackSize()))
-		fatalErr(err)
+		b, err := readBlobValue(r, tableMap.Metadata[columnIndex])
+		if err != nil {
+			return nil, err
+		}
 
-		return BlobRowImageCell(b)
+		return BlobRowImageCell(b), nil
 
-	case MYSQL_TYPE_DECIMAL, MYSQL_TYPE_GEOMETRY:
-		log.Fatal("Mysql type discovered but not supported at this time.")
+	case MYSQL_TYPE_JSON:
+		b, err := readBlobValue(r, tableMap.Metadata[columnIndex])
+		if err != nil {
+			return nil, err
+		}
+
+		document, err := decodeBinaryJSON(b)
+		if err != nil {
+			return nil, err
+		}
+
+		return JsonRowImageCell{
+			value: document.value,
+			text:  document.text,
+		}, nil
+
+	case MYSQL_TYPE_GEOMETRY:
+		b, err := readBlobValue(r, tableMap.Metadata[columnIndex])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(b) < 4 {
+			return nil, fmt.Errorf("geometry value of %v bytes is too short for its SRID", len(b))
+		}
+
+		return GeometryRowImageCell{
+			srid: binary.LittleEndian.Uint32(b),
+			wkb:  b[4:],
+		}, nil
+
+	case MYSQL_TYPE_DECIMAL:
+		// Mysql type discovered but not supported at this time
+		return nil, &ErrUnsupportedType{MysqlType: mysqlType}
 
 	default:
-		log.Fatal("Unsupported mysql type:", mysqlType)
+		return nil, &ErrUnsupportedType{MysqlType: mysqlType}
 	}
-
-	return NewNullRowImageCell(mysqlType)
 }
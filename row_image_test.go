@@ -0,0 +1,473 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNumberRowImageCell(t *testing.T) {
+	tests := []struct {
+		mysqlType byte
+		value     uint64
+		signed    int64
+	}{
+		{MYSQL_TYPE_TINY, 0x7f, 127},
+		{MYSQL_TYPE_TINY, 0x80, -128},
+		{MYSQL_TYPE_TINY, 0xff, -1},
+		{MYSQL_TYPE_SHORT, 0x7fff, 32767},
+		{MYSQL_TYPE_SHORT, 0x8000, -32768},
+		{MYSQL_TYPE_SHORT, 0xffff, -1},
+		{MYSQL_TYPE_INT24, 0x7fffff, 8388607},
+		{MYSQL_TYPE_INT24, 0x800000, -8388608},
+		{MYSQL_TYPE_INT24, 0xfffffe, -2},
+		{MYSQL_TYPE_LONG, 0x7fffffff, math.MaxInt32},
+		{MYSQL_TYPE_LONG, 0x80000000, math.MinInt32},
+		{MYSQL_TYPE_LONG, 0xffffffff, -1},
+		{MYSQL_TYPE_LONGLONG, math.MaxInt64, math.MaxInt64},
+		{MYSQL_TYPE_LONGLONG, 1 << 63, math.MinInt64},
+		{MYSQL_TYPE_LONGLONG, math.MaxUint64, -1},
+		{MYSQL_TYPE_LONG, 0, 0},
+	}
+
+	for _, test := range tests {
+		signed := NewNumberRowImageCell(test.mysqlType, test.value, false)
+		unsigned := NewNumberRowImageCell(test.mysqlType, test.value, true)
+
+		assert.Equal(t, test.signed, signed.Int64(), "%v %x", test.mysqlType, test.value)
+		assert.Equal(t, test.value, signed.Uint64(), "%v %x", test.mysqlType, test.value)
+		assert.Equal(t, test.signed, signed.Value())
+		assert.Equal(t, test.value, unsigned.Value())
+		assert.Equal(t, test.signed, unsigned.Int64())
+	}
+
+	assert.Equal(t, "-1", NewNumberRowImageCell(MYSQL_TYPE_INT24, 0xffffff, false).String())
+	assert.Equal(t, "16777215", NewNumberRowImageCell(MYSQL_TYPE_INT24, 0xffffff, true).String())
+}
+
+func TestSignedIntegerColumns(t *testing.T) {
+	columnTypes := []byte{MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_INT24, MYSQL_TYPE_LONG, MYSQL_TYPE_LONGLONG}
+	row := []byte{
+		0x00,
+		0xff,
+		0xfe, 0xff,
+		0xfd, 0xff, 0xff,
+		0xfc, 0xff, 0xff, 0xff,
+		0xfb, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}
+
+	readRow := func(tableMap testEvent, options ...Option) RowImage {
+		b := openTestBinlog(t, makeChecksummedTestBinlog(
+			tableMap,
+			rowsTestEvent(WRITE_ROWS_EVENTv2, len(columnTypes), []byte{0x1f}, row),
+		), options...)
+
+		_, err := b.NextEvent()
+		checkErr(t, err)
+
+		event, err := b.NextEvent()
+		checkErr(t, err)
+
+		if event == nil {
+			return nil
+		}
+
+		return event.Data().(*RowsEvent).Rows[0]
+	}
+
+	values := func(row RowImage) []interface{} {
+		v := []interface{}{}
+
+		for _, cell := range row {
+			v = append(v, cell.(NumberRowImageCell).Value())
+		}
+
+		return v
+	}
+
+	// Signed when nothing says otherwise
+	assert.Equal(t, []interface{}{int64(-1), int64(-2), int64(-3), int64(-4), int64(-5)}, values(readRow(tableMapTestEvent(columnTypes, nil))))
+
+	// From the optional metadata: INT24 and LONGLONG unsigned
+	withSignedness := tableMapTestEvent(columnTypes, nil)
+	withSignedness.payload = append(withSignedness.payload, SIGNEDNESS, 1, 0x28)
+
+	assert.Equal(t,
+		[]interface{}{int64(-1), int64(-2), uint64(0xfffffd), int64(-4), uint64(math.MaxUint64 - 4)},
+		values(readRow(withSignedness)),
+	)
+
+	// From the schema, unless the optional metadata has it
+	schema := Schema{"test.t": {Unsigned: []bool{true, true, false, false, false}}}
+
+	assert.Equal(t,
+		[]interface{}{uint64(0xff), uint64(0xfffe), int64(-3), int64(-4), int64(-5)},
+		values(readRow(tableMapTestEvent(columnTypes, nil), WithSchema(schema))),
+	)
+
+	assert.Equal(t,
+		[]interface{}{int64(-1), int64(-2), uint64(0xfffffd), int64(-4), uint64(math.MaxUint64 - 4)},
+		values(readRow(withSignedness, WithSchema(schema))),
+	)
+}
+
+func TestNewDecimal(t *testing.T) {
+	tests := []struct {
+		precision byte
+		decimals  byte
+		data      []byte
+		expected  string
+	}{
+		{14, 4, []byte{0x81, 0x0d, 0xfb, 0x38, 0xd2, 0x04, 0xd2}, "1234567890.1234"},
+		{14, 4, []byte{0x7e, 0xf2, 0x04, 0xc7, 0x2d, 0xfb, 0x2d}, "-1234567890.1234"},
+		{10, 2, []byte{0x80, 0x00, 0x00, 0x00, 0x00}, "0.00"},
+		{10, 2, []byte{0x80, 0x00, 0x00, 0x00, 0x01}, "0.01"},
+		{10, 2, []byte{0x7f, 0xff, 0xff, 0xff, 0xfe}, "-0.01"},
+		{10, 0, []byte{0x80, 0x00, 0x00, 0x00, 0x07}, "7"},
+		{5, 5, []byte{0x81, 0x86, 0x9f}, "0.99999"},
+		// 9 digit groups on both sides
+		{18, 9, []byte{0x87, 0x5b, 0xcd, 0x15, 0x3a, 0xde, 0x68, 0xb1}, "123456789.987654321"},
+		{65, 30, []byte{
+			0x85, 0xf5, 0xe0, 0xff, 0x07, 0x5b, 0xcd, 0x15, 0x07, 0x5b, 0xcd, 0x15, 0x07, 0x5b, 0xcd, 0x15,
+			0x00, 0x00, 0x00, 0x01, 0x3a, 0xde, 0x68, 0xb1, 0x00, 0x00, 0x00, 0x00, 0x00, 0x7b,
+		}, "99999999123456789123456789123456789.000000001987654321000000000123"},
+	}
+
+	for _, test := range tests {
+		tableMap := &TableMapEvent{
+			ColumnTypes: []byte{MYSQL_TYPE_NEWDECIMAL},
+			Metadata:    []*ColumnMetadata{{data: []byte{test.precision, test.decimals}, metaType: NEW_DECIMAL_METADATA}},
+		}
+
+		r := bytes.NewReader(test.data)
+		cell, err := DeserializeRowImageCell(r, tableMap, 0)
+		checkErr(t, err)
+
+		if decimal, ok := cell.(DecimalRowImageCell); assert.True(t, ok, test.expected) {
+			assert.Equal(t, test.expected, decimal.String())
+			assert.Equal(t, int(test.decimals), decimal.Scale())
+
+			unscaled, _ := new(big.Int).SetString(strings.Replace(test.expected, ".", "", 1), 10)
+			assert.Equal(t, 0, unscaled.Cmp(decimal.Unscaled()), test.expected)
+		}
+
+		assert.Equal(t, 0, r.Len(), "%v bytes left over for %v", r.Len(), test.expected)
+	}
+
+	_, _, err := ReadNewDecimal(bytes.NewReader(nil), &ColumnMetadata{data: []byte{70, 2}, metaType: NEW_DECIMAL_METADATA})
+	assert.Error(t, err)
+	_, _, err = ReadNewDecimal(bytes.NewReader([]byte{0x80}), &ColumnMetadata{data: []byte{10, 2}, metaType: NEW_DECIMAL_METADATA})
+	assert.Error(t, err)
+}
+
+func TestDecimalRowImageCellString(t *testing.T) {
+	assert.Equal(t, "-0.05", NewDecimalRowImageCell(big.NewInt(-5), 2).String())
+	assert.Equal(t, "100", NewDecimalRowImageCell(big.NewInt(100), 0).String())
+	assert.Equal(t, "1.000", NewDecimalRowImageCell(big.NewInt(1000), 3).String())
+}
+
+func TestStringColumns(t *testing.T) {
+	varchar := func(maxLength uint16) *ColumnMetadata {
+		return &ColumnMetadata{data: []byte{byte(maxLength), byte(maxLength >> 8)}, metaType: VARCHAR_METADATA}
+	}
+
+	str := func(realType, length byte) *ColumnMetadata {
+		return &ColumnMetadata{data: []byte{realType, length}, metaType: STRING_METADATA}
+	}
+
+	longValue := bytes.Repeat([]byte{'z'}, 1000)
+
+	tests := []struct {
+		mysqlType byte
+		metadata  *ColumnMetadata
+		data      []byte
+		expected  RowImageCell
+	}{
+		{MYSQL_TYPE_VARCHAR, varchar(20), []byte{3, 'a', 'b', 'c'}, NewStringRowImageCell(MYSQL_TYPE_VARCHAR, "abc")},
+		{MYSQL_TYPE_VARCHAR, varchar(20), []byte{0}, NewStringRowImageCell(MYSQL_TYPE_VARCHAR, "")},
+		{MYSQL_TYPE_VARCHAR, varchar(255), []byte{2, 'h', 'i'}, NewStringRowImageCell(MYSQL_TYPE_VARCHAR, "hi")},
+		{MYSQL_TYPE_VARCHAR, varchar(256), []byte{2, 0, 'h', 'i'}, NewStringRowImageCell(MYSQL_TYPE_VARCHAR, "hi")},
+		{MYSQL_TYPE_VARCHAR, varchar(1200), append([]byte{0xe8, 0x03}, longValue...), NewStringRowImageCell(MYSQL_TYPE_VARCHAR, string(longValue))},
+		{MYSQL_TYPE_STRING, str(MYSQL_TYPE_STRING, 10), []byte{2, 'x', 'y'}, NewStringRowImageCell(MYSQL_TYPE_STRING, "xy")},
+		{MYSQL_TYPE_VAR_STRING, str(MYSQL_TYPE_VAR_STRING, 10), []byte{1, 'x'}, NewStringRowImageCell(MYSQL_TYPE_VAR_STRING, "x")},
+		// CHAR(255) in utf8mb4 is 1020 bytes: the real type carries 0x300
+		{MYSQL_TYPE_STRING, str(MYSQL_TYPE_STRING ^ 0x30, 0xfc), append([]byte{0xe8, 0x03}, longValue...), NewStringRowImageCell(MYSQL_TYPE_STRING, string(longValue))},
+		{MYSQL_TYPE_STRING, str(MYSQL_TYPE_ENUM, 1), []byte{2}, EnumRowImageCell(2)},
+		{MYSQL_TYPE_STRING, str(MYSQL_TYPE_ENUM, 2), []byte{0x2c, 0x01}, EnumRowImageCell(300)},
+		{MYSQL_TYPE_STRING, str(MYSQL_TYPE_SET, 1), []byte{0x05}, SetRowImageCell(5)},
+		{MYSQL_TYPE_STRING, str(MYSQL_TYPE_SET, 3), []byte{0x05, 0x00, 0x01}, SetRowImageCell(0x010005)},
+		{MYSQL_TYPE_STRING, str(MYSQL_TYPE_SET, 8), []byte{1, 0, 0, 0, 0, 0, 0, 0x80}, SetRowImageCell(0x8000000000000001)},
+	}
+
+	for _, test := range tests {
+		tableMap := &TableMapEvent{ColumnTypes: []byte{test.mysqlType}, Metadata: []*ColumnMetadata{test.metadata}}
+
+		r := bytes.NewReader(test.data)
+		cell, err := DeserializeRowImageCell(r, tableMap, 0)
+		checkErr(t, err)
+
+		assert.Equal(t, test.expected, cell)
+		assert.Equal(t, 0, r.Len(), "%v bytes left over for %v", r.Len(), test.expected)
+	}
+
+	for _, test := range []struct {
+		mysqlType byte
+		metadata  *ColumnMetadata
+		data      []byte
+	}{
+		{MYSQL_TYPE_VARCHAR, varchar(20), []byte{21}},
+		{MYSQL_TYPE_VARCHAR, varchar(20), []byte{5, 'a'}},
+		{MYSQL_TYPE_STRING, str(MYSQL_TYPE_ENUM, 3), []byte{1, 0, 0}},
+		{MYSQL_TYPE_STRING, str(MYSQL_TYPE_SET, 9), make([]byte, 9)},
+	} {
+		tableMap := &TableMapEvent{ColumnTypes: []byte{test.mysqlType}, Metadata: []*ColumnMetadata{test.metadata}}
+
+		_, err := DeserializeRowImageCell(bytes.NewReader(test.data), tableMap, 0)
+		assert.Error(t, err, "%v", test.data)
+	}
+}
+
+func TestStringRealTypeAndLength(t *testing.T) {
+	for _, test := range []struct {
+		data     []byte
+		realType byte
+		length   uint16
+	}{
+		{[]byte{MYSQL_TYPE_STRING, 40}, MYSQL_TYPE_STRING, 40},
+		{[]byte{MYSQL_TYPE_STRING ^ 0x10, 0x2c}, MYSQL_TYPE_STRING, 0x12c},
+		{[]byte{MYSQL_TYPE_STRING ^ 0x30, 0xfc}, MYSQL_TYPE_STRING, 0x3fc},
+		{[]byte{MYSQL_TYPE_ENUM, 2}, MYSQL_TYPE_ENUM, 2},
+		{[]byte{MYSQL_TYPE_SET, 8}, MYSQL_TYPE_SET, 8},
+	} {
+		realType, length, err := (&ColumnMetadata{data: test.data, metaType: STRING_METADATA}).RealTypeAndLength()
+		checkErr(t, err)
+		assert.Equal(t, test.realType, realType)
+		assert.Equal(t, test.length, length)
+	}
+}
+
+func TestEnumAndSetValues(t *testing.T) {
+	values := []string{"a", "b", "c"}
+
+	assert.Equal(t, "", EnumRowImageCell(0).Label(values))
+	assert.Equal(t, "a", EnumRowImageCell(1).Label(values))
+	assert.Equal(t, "c", EnumRowImageCell(3).Label(values))
+	assert.Equal(t, "", EnumRowImageCell(4).Label(values))
+
+	assert.Equal(t, []string{}, SetRowImageCell(0).Members(values))
+	assert.Equal(t, []string{"a", "c"}, SetRowImageCell(5).Members(values))
+	assert.Equal(t, []string{"b"}, SetRowImageCell(0xfa).Members(values))
+}
+
+func TestBitColumns(t *testing.T) {
+	for n := 1; n <= 64; n++ {
+		metadata := &ColumnMetadata{data: []byte{byte(n % 8), byte(n / 8)}, metaType: BITSET_METADATA}
+		tableMap := &TableMapEvent{ColumnTypes: []byte{MYSQL_TYPE_BIT}, Metadata: []*ColumnMetadata{metadata}}
+		size := (n + 7) / 8
+
+		// All ones, and the top and bottom bits alone
+		for _, value := range []uint64{math.MaxUint64 >> uint(64 - n), 1 << uint(n - 1), 1} {
+			data := make([]byte, size)
+			for i := range data {
+				data[i] = byte(value >> uint(8 * (size - 1 - i)))
+			}
+
+			r := bytes.NewReader(data)
+			cell, err := DeserializeRowImageCell(r, tableMap, 0)
+			checkErr(t, err)
+
+			if bit, ok := cell.(BitRowImageCell); assert.True(t, ok, "BIT(%v)", n) {
+				assert.Equal(t, n, bit.Bits())
+				assert.Equal(t, data, bit.Bytes(), "BIT(%v) %x", n, value)
+				assert.Equal(t, value, bit.Uint64(), "BIT(%v) %x", n, value)
+				assert.Len(t, bit.String(), n + 3)
+			}
+
+			assert.Equal(t, 0, r.Len(), "BIT(%v)", n)
+		}
+	}
+
+	assert.Equal(t, "b'00101'", NewBitRowImageCell(5, []byte{0x05}).String())
+	assert.Equal(t, "b'1000000001'", NewBitRowImageCell(10, []byte{0x02, 0x01}).String())
+
+	tableMap := &TableMapEvent{
+		ColumnTypes: []byte{MYSQL_TYPE_BIT},
+		Metadata:    []*ColumnMetadata{{data: []byte{1, 8}, metaType: BITSET_METADATA}},
+	}
+
+	_, err := DeserializeRowImageCell(bytes.NewReader(make([]byte, 9)), tableMap, 0)
+	assert.Error(t, err)
+}
+
+func TestLegacyTemporalColumns(t *testing.T) {
+	tests := []struct {
+		mysqlType byte
+		data      []byte
+		value     interface{}
+	}{
+		// 1400000000
+		{MYSQL_TYPE_TIMESTAMP, []byte{0x00, 0x4e, 0x72, 0x53}, time.Date(2014, 5, 13, 16, 53, 20, 0, time.UTC)},
+		{MYSQL_TYPE_TIMESTAMP, []byte{0, 0, 0, 0}, time.Time{}},
+		// 2014 << 9 | 5 << 5 | 21
+		{MYSQL_TYPE_DATE, []byte{0xb5, 0xbc, 0x0f}, time.Date(2014, 5, 21, 0, 0, 0, 0, time.UTC)},
+		{MYSQL_TYPE_DATE, []byte{0x21, 0x02, 0x00}, time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{MYSQL_TYPE_DATE, []byte{0, 0, 0}, time.Time{}},
+		// 20140521153012
+		{MYSQL_TYPE_DATETIME, []byte{0xf4, 0xe1, 0x9b, 0x54, 0x51, 0x12, 0x00, 0x00}, time.Date(2014, 5, 21, 15, 30, 12, 0, time.UTC)},
+		{MYSQL_TYPE_DATETIME, make([]byte, 8), time.Time{}},
+	}
+
+	for _, test := range tests {
+		tableMap := &TableMapEvent{ColumnTypes: []byte{test.mysqlType}, Metadata: []*ColumnMetadata{nil}}
+
+		r := bytes.NewReader(test.data)
+		cell, err := DeserializeRowImageCell(r, tableMap, 0)
+		checkErr(t, err)
+
+		if v, ok := cell.(TimeRowImageCell); assert.True(t, ok, "type %v", test.mysqlType) {
+			assert.Equal(t, test.mysqlType, v.Type())
+			assert.Equal(t, test.value, v.Time(), "type %v", test.mysqlType)
+		}
+
+		assert.Equal(t, 0, r.Len())
+	}
+
+	durations := []struct {
+		data  []byte
+		value time.Duration
+	}{
+		{[]byte{0x40, 0xe2, 0x01}, 12 * time.Hour + 34 * time.Minute + 56 * time.Second},   // 123456
+		{[]byte{0x00, 0x00, 0x00}, 0},
+		{[]byte{0x59, 0x0a, 0x80}, -(838 * time.Hour + 59 * time.Minute + 59 * time.Second)}, // -8385959
+		{[]byte{0xff, 0xff, 0xff}, -time.Second},
+	}
+
+	tableMap := &TableMapEvent{ColumnTypes: []byte{MYSQL_TYPE_TIME}, Metadata: []*ColumnMetadata{nil}}
+
+	for _, test := range durations {
+		cell, err := DeserializeRowImageCell(bytes.NewReader(test.data), tableMap, 0)
+		checkErr(t, err)
+		assert.Equal(t, DurationRowImageCell(test.value), cell, "%x", test.data)
+	}
+
+	_, err := DeserializeRowImageCell(bytes.NewReader(make([]byte, 7)), &TableMapEvent{ColumnTypes: []byte{MYSQL_TYPE_DATETIME}, Metadata: []*ColumnMetadata{nil}}, 0)
+	assert.Error(t, err)
+}
+
+func TestFloatingPointColumns(t *testing.T) {
+	packSize := func(size byte) []*ColumnMetadata {
+		return []*ColumnMetadata{{data: []byte{size}, metaType: PACK_SIZE_METADATA}}
+	}
+
+	doubles := []float64{
+		0,
+		math.Copysign(0, -1),
+		1.5,
+		-2.25,
+		math.MaxFloat64,
+		-math.MaxFloat64,
+		math.SmallestNonzeroFloat64,
+		-math.SmallestNonzeroFloat64,
+		math.Inf(1),
+	}
+
+	tableMap := &TableMapEvent{ColumnTypes: []byte{MYSQL_TYPE_DOUBLE}, Metadata: packSize(8)}
+
+	for _, value := range doubles {
+		data := make([]byte, 8)
+		binary.LittleEndian.PutUint64(data, math.Float64bits(value))
+
+		r := bytes.NewReader(data)
+		cell, err := DeserializeRowImageCell(r, tableMap, 0)
+		checkErr(t, err)
+
+		if v, ok := cell.(DoubleRowImageCell); assert.True(t, ok, "%v", value) {
+			// Compare bits so -0 and 0 differ
+			assert.Equal(t, math.Float64bits(value), math.Float64bits(float64(v)), "%v", value)
+		}
+
+		assert.Equal(t, 0, r.Len())
+	}
+
+	floats := []float32{
+		0,
+		float32(math.Copysign(0, -1)),
+		3.25,
+		math.MaxFloat32,
+		math.SmallestNonzeroFloat32,
+		-math.SmallestNonzeroFloat32,
+	}
+
+	tableMap = &TableMapEvent{ColumnTypes: []byte{MYSQL_TYPE_FLOAT}, Metadata: packSize(4)}
+
+	for _, value := range floats {
+		data := make([]byte, 4)
+		binary.LittleEndian.PutUint32(data, math.Float32bits(value))
+
+		r := bytes.NewReader(data)
+		cell, err := DeserializeRowImageCell(r, tableMap, 0)
+		checkErr(t, err)
+
+		if v, ok := cell.(FloatingPointNumberRowImageCell); assert.True(t, ok, "%v", value) {
+			assert.Equal(t, math.Float32bits(value), math.Float32bits(float32(v)), "%v", value)
+		}
+
+		assert.Equal(t, 0, r.Len())
+	}
+
+	// A FLOAT column whose metadata says 8 bytes is read as a double
+	tableMap = &TableMapEvent{ColumnTypes: []byte{MYSQL_TYPE_FLOAT}, Metadata: packSize(8)}
+	cell, err := DeserializeRowImageCell(bytes.NewReader([]byte{0, 0, 0, 0, 0, 0, 0xf8, 0x3f}), tableMap, 0)
+	checkErr(t, err)
+	assert.Equal(t, DoubleRowImageCell(1.5), cell)
+
+	tableMap = &TableMapEvent{ColumnTypes: []byte{MYSQL_TYPE_DOUBLE}, Metadata: packSize(3)}
+	_, err = DeserializeRowImageCell(bytes.NewReader(make([]byte, 8)), tableMap, 0)
+	assert.Error(t, err)
+
+	tableMap = &TableMapEvent{ColumnTypes: []byte{MYSQL_TYPE_DOUBLE}, Metadata: packSize(8)}
+	_, err = DeserializeRowImageCell(bytes.NewReader(make([]byte, 7)), tableMap, 0)
+	assert.Error(t, err)
+}
+
+func TestLegacyZeroInDateColumns(t *testing.T) {
+	tests := []struct {
+		mysqlType byte
+		data      []byte
+		value     MysqlDatetime
+		text      string
+	}{
+		// 2014 << 9
+		{MYSQL_TYPE_DATE, []byte{0x00, 0xbc, 0x0f}, MysqlDatetime{Year: 2014}, "2014-00-00"},
+		// 20140000000000
+		{MYSQL_TYPE_DATETIME, []byte{0x00, 0xb8, 0x8b, 0x35, 0x51, 0x12, 0x00, 0x00}, MysqlDatetime{Year: 2014}, "2014-00-00 00:00:00"},
+		// 20140500123000
+		{MYSQL_TYPE_DATETIME, []byte{0x78, 0xfd, 0x5a, 0x53, 0x51, 0x12, 0x00, 0x00}, MysqlDatetime{Year: 2014, Month: 5, Hour: 12, Minute: 30}, "2014-05-00 12:30:00"},
+	}
+
+	for _, test := range tests {
+		tableMap := &TableMapEvent{ColumnTypes: []byte{test.mysqlType}, Metadata: []*ColumnMetadata{nil}}
+
+		cell, err := DeserializeRowImageCell(bytes.NewReader(test.data), tableMap, 0)
+		checkErr(t, err)
+
+		// Never normalized into a different, real date
+		if v, ok := cell.(ZeroInDateRowImageCell); assert.True(t, ok, "%v got %v", test.text, cell) {
+			assert.Equal(t, test.mysqlType, v.Type())
+			assert.Equal(t, test.value, v.Datetime())
+			assert.Equal(t, test.text, v.String())
+		}
+	}
+
+	_, err := ReadDate(bytes.NewReader([]byte{0x00, 0xbc, 0x0f}))
+	assert.Error(t, err)
+
+	_, err = ReadDatetimeV1(bytes.NewReader([]byte{0x78, 0xfd, 0x5a, 0x53, 0x51, 0x12, 0x00, 0x00}))
+	assert.Error(t, err)
+}
@@ -1,21 +1,106 @@
-package main
+package binlog
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"log"
 )
 
-type RowsEvent struct {
-	dataType        byte
+// What a rows event did to its rows
+type RowsOperation byte
+
+const (
+	ROWS_OPERATION_INSERT RowsOperation = iota + 1
+	ROWS_OPERATION_UPDATE
+	ROWS_OPERATION_DELETE
+)
+
+func (o RowsOperation) String() string {
+	switch o {
+	case ROWS_OPERATION_INSERT:
+		return "INSERT"
+	case ROWS_OPERATION_UPDATE:
+		return "UPDATE"
+	case ROWS_OPERATION_DELETE:
+		return "DELETE"
+	}
+
+	return fmt.Sprintf("RowsOperation(%v)", byte(o))
+}
+
+// Rows event flags
+const (
+	STMT_END_F              uint16 = 1 << iota // last event of the statement
+	NO_FOREIGN_KEY_CHECKS_F                    // foreign_key_checks was off
+	RELAXED_UNIQUE_CHECKS_F                    // unique_checks was off
+	COMPLETE_ROWS_F                            // the rows hold every column (binlog_row_image=FULL)
+)
+
+// Fields common to write, update and delete rows events
+type RowsEventHeader struct {
+	typeCode        byte
+	Operation       RowsOperation
+	Version         int // 0 (5.1.0-5.1.15), 1 (5.1.16-5.6) or 2 (5.6+)
 	TableId         uint64
+	Flags           uint16
+	ExtraData       *RowsExtraData // nil unless Version is 2
 	NumberOfColumns uint64
-	UsedSet         Bitset
-	Rows            []RowImage
 }
 
-func (e *RowsEvent) Type() byte {
-	return e.dataType
+// The event type code, e.g. WRITE_ROWS_EVENTv2
+func (h *RowsEventHeader) Type() byte {
+	return h.typeCode
+}
+
+// Reports whether this is the last rows event of its statement, after
+// which the table maps it used may be released
+func (h *RowsEventHeader) StatementEnd() bool {
+	return h.Flags & STMT_END_F != 0
+}
+
+func (h *RowsEventHeader) NoForeignKeyChecks() bool {
+	return h.Flags & NO_FOREIGN_KEY_CHECKS_F != 0
+}
+
+func (h *RowsEventHeader) RelaxedUniqueChecks() bool {
+	return h.Flags & RELAXED_UNIQUE_CHECKS_F != 0
+}
+
+func (h *RowsEventHeader) CompleteRows() bool {
+	return h.Flags & COMPLETE_ROWS_F != 0
+}
+
+// Returns the operation and version of a rows event type code
+func rowsEventKind(typeCode byte) (RowsOperation, int, error) {
+	switch typeCode {
+	case WRITE_ROWS_EVENTv0:
+		return ROWS_OPERATION_INSERT, 0, nil
+	case UPDATE_ROWS_EVENTv0:
+		return ROWS_OPERATION_UPDATE, 0, nil
+	case DELETE_ROWS_EVENTv0:
+		return ROWS_OPERATION_DELETE, 0, nil
+	case WRITE_ROWS_EVENTv1:
+		return ROWS_OPERATION_INSERT, 1, nil
+	case UPDATE_ROWS_EVENTv1:
+		return ROWS_OPERATION_UPDATE, 1, nil
+	case DELETE_ROWS_EVENTv1:
+		return ROWS_OPERATION_DELETE, 1, nil
+	case WRITE_ROWS_EVENTv2:
+		return ROWS_OPERATION_INSERT, 2, nil
+	case UPDATE_ROWS_EVENTv2:
+		return ROWS_OPERATION_UPDATE, 2, nil
+	case DELETE_ROWS_EVENTv2:
+		return ROWS_OPERATION_DELETE, 2, nil
+	}
+
+	return 0, 0, fmt.Errorf("event type %v is not a rows event", typeCode)
+}
+
+// A write or delete rows event. Updates are read into an UpdateRowsEvent.
+type RowsEvent struct {
+	RowsEventHeader
+	UsedSet Bitset
+	Rows    []RowImage
 }
 
 func (e *RowsEvent) UsedFields() int {
@@ -30,6 +115,9 @@ func (e *RowsEvent) UsedFields() int {
 	return used
 }
 
+// Post-header length of the v2 rows events, which carry extra data
+const ROWS_HEADER_LEN_V2 = 10
+
 type RowsEventDeserializer struct {}
 
 /*
@@ -42,98 +130,200 @@ N = (7 + number of columns) / 8
 J = (7 + number of true bits in column used bitfield) / 8
 K = number of false bits in null bitfield (not counting padding in last byte)
 U = 2 if update event, 1 for any other ones
+X = extra data length - 2
 B = number of rows (determined by reading till data length reached)
 
-Fixed Section:
-6 bytes = table id
-2 bytes = reserved (skip)
+Fixed Section (post-header):
+6 bytes = table id (4 bytes if the post-header is 6 bytes long)
+2 bytes = flags (STMT_END_F etc.)
+v2 only, when the post-header is ROWS_HEADER_LEN_V2 long:
+2 bytes = extra data length, including these 2 bytes
+X bytes = extra data (see rows_extra_data.go)
 
 Variable Section:
 1 byte  = packed int byte key (see ReadPackedInteger)
 P bytes = number of columns
-N bytes = column used bitfield
-U * B * (
+N bytes = column used bitfield (before image for updates)
+update only:
+N bytes = after image column used bitfield
+B * U * (
 	J bytes = null bitfield
 	K bytes = row image
 )
 
+Update events write the before and after image of each row one after
+the other, each with its own null bitfield sized by its own used
+bitfield. They are read by UpdateRowsEventDeserializer.
+
 FOR ROW IMAGE CELL DESERIALIZATION:
 http://bazaar.launchpad.net/~mysql/mysql-server/5.6/view/head:/sql/log_event.cc#L1942
 
 */
 
-func (d *RowsEventDeserializer) Deserialize(reader io.ReadSeeker, header *EventHeader) EventData {
-	{
-		b, err := ReadBytes(reader, 16)
-		fatalErr(err)
+func (d *RowsEventDeserializer) Deserialize(b *Binlog, reader io.ReadSeeker, header *EventHeader) (EventData, error) {
+	e := new(RowsEvent)
 
-		fmt.Println("rows data bytes:", b)
+	tableMap, err := readRowsEventHeader(b, reader, header, &e.RowsEventHeader)
+	if err != nil {
+		return nil, err
+	}
 
-		_, err = reader.Seek(-16, 1)
-		fatalErr(err)
+	e.UsedSet, err = ReadBitset(reader, int(e.NumberOfColumns))
+	if err != nil {
+		return nil, err
 	}
 
-	e := new(RowsEvent)
-	e.dataType = 'a' // TODO
+	e.Rows = []RowImage{}
+
+	err = readRows(reader, e.TableId, func() error {
+		row, err := readRowImage(reader, tableMap, e.UsedSet, e.NumberOfColumns)
+		if err != nil {
+			return err
+		}
+
+		e.Rows = append(e.Rows, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b.logger.Log(LOG_LEVEL_DEBUG, "Rows event",
+		Field("operation", e.Operation),
+		Field("version", e.Version),
+		Field("table_id", e.TableId),
+		Field("flags", e.Flags),
+		Field("columns", e.NumberOfColumns),
+		Field("used_set", e.UsedSet),
+		Field("rows", len(e.Rows)),
+	)
+
+	return e, nil
+}
+
+// Reads the post-header and column count common to all rows events and
+// finds the table map they refer to
+func readRowsEventHeader(b *Binlog, reader io.ReadSeeker, header *EventHeader, h *RowsEventHeader) (*TableMapEvent, error) {
+	postHeaderLength := b.formatDescription.PostHeaderLength(header.Type)
+	tableIdLength := b.formatDescription.tableIdLength(header.Type)
 
 	var err error
-	e.TableId, err = ReadTableId(reader)
-	fatalErr(err)
+	h.typeCode = header.Type
 
-	_, err = reader.Seek(2, 1) // reserved
-	fatalErr(err)
+	h.Operation, h.Version, err = rowsEventKind(header.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	h.TableId, err = ReadTableId(reader, tableIdLength)
+	if err != nil {
+		return nil, err
+	}
+
+	h.Flags, err = ReadUint16(reader)
+	if err != nil {
+		return nil, err
+	}
 
 	// v2 row events
-	switch header.Type {
-		case WRITE_ROWS_EVENTv2, UPDATE_ROWS_EVENTv2, DELETE_ROWS_EVENTv2:
-			extraInfoLength, err := ReadUint16(reader)
-			fatalErr(err)
+	if postHeaderLength == ROWS_HEADER_LEN_V2 {
+		h.ExtraData, err = readRowsExtraData(reader, h.Operation)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-			_, err = reader.Seek(int64(extraInfoLength - 2), 1)
-			fatalErr(err)
+	h.NumberOfColumns, err = ReadPackedInteger(reader)
+	if err != nil {
+		return nil, err
 	}
 
-	e.NumberOfColumns, err = ReadPackedInteger(reader)
-	fatalErr(err)
+	tableMap, ok := b.tableMaps[h.TableId]
+	if !ok {
+		return nil, &ErrMissingTableMap{TableId: h.TableId}
+	}
 
-	e.UsedSet, err = ReadBitset(reader, int(e.NumberOfColumns))
-	fatalErr(err)
+	if int(h.NumberOfColumns) > len(tableMap.ColumnTypes) {
+		return nil, fmt.Errorf("rows event has %v columns but the table map for table %v has %v", h.NumberOfColumns, h.TableId, len(tableMap.ColumnTypes))
+	}
 
-	numberOfFields := e.UsedFields()
-	numberOfRows := 1 // TODO: pass in header so we can check if it is update
-	e.Rows = make([]RowImage, numberOfRows)
+	return tableMap, nil
+}
+
+// Calls readRow until the reader reaches the end of the payload, as
+// there is no row count. A row that runs past the end is an error.
+func readRows(reader io.ReadSeeker, tableId uint64, readRow func() error) error {
+	position, err := reader.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
 
-	// TODO
-	for r := 0; r < numberOfRows; r++ {
-		nullSet, err := ReadBitset(reader, numberOfFields)
-		fatalErr(err)
+	payloadLength, err := reader.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
 
-		// TODO: fork this off into bitset.go in a way that makes sense
-		if len(e.UsedSet) != len(nullSet) {
-			log.Fatal("UsedSet and NullSet length mismatched", len(e.UsedSet), len(nullSet), numberOfFields, e.UsedSet, e.NumberOfColumns, e.TableId)
+	if _, err = reader.Seek(position, io.SeekStart); err != nil {
+		return err
+	}
+
+	for row := 0; position < payloadLength; row++ {
+		err = readRow()
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("row %v of table %v runs past the end of the event", row, tableId)
+		} else if err != nil {
+			return err
 		}
 
-		cells := make(RowImage, e.NumberOfColumns)
-		tableMap, ok := GetTableMapCollectionInstance()[e.TableId]
+		position, err = reader.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+	}
+
+	if position > payloadLength {
+		return fmt.Errorf("rows event header overran the event by %v bytes", position - payloadLength)
+	}
+
+	return nil
+}
+
+// Reads one row image: a null bitfield with a bit for each column in
+// usedSet, followed by the values of the used columns that aren't null.
+// Columns missing from usedSet are left nil.
+func readRowImage(r io.Reader, tableMap *TableMapEvent, usedSet Bitset, numberOfColumns uint64) (RowImage, error) {
+	used := 0
+
+	for i := uint(0); i < uint(numberOfColumns); i++ {
+		if usedSet.Bit(i) {
+			used++
+		}
+	}
+
+	nullSet, err := ReadBitset(r, used)
+	if err != nil {
+		return nil, err
+	}
+
+	cells := make(RowImage, numberOfColumns)
+	field := uint(0)
 
-		if !ok {
-			log.Fatal("Never recieved table map event for table:", e.TableId)
+	for i := 0; i < int(numberOfColumns); i++ {
+		if !usedSet.Bit(uint(i)) {
+			continue
 		}
 
-		for i := 0; i < int(e.NumberOfColumns); i++ {
-			if e.UsedSet.Bit(uint(i)) {
-				if nullSet.Bit(uint(i)) {
-					cells[i] = NewNullRowImageCell(tableMap.ColumnTypes[i])
-				} else {
-					cells[i] = DeserializeRowImageCell(reader, tableMap, i)
-				}
-			} else {
-				cells[i] = nil
+		if nullSet.Bit(field) {
+			cells[i] = NewNullRowImageCell(tableMap.ColumnTypes[i])
+		} else {
+			cells[i], err = DeserializeRowImageCell(r, tableMap, i)
+			if err != nil {
+				return nil, err
 			}
 		}
 
-		e.Rows[r] = cells
+		field++
 	}
 
-	return e
+	return cells, nil
 }
@@ -0,0 +1,362 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testTableId = 42
+
+func writeTestTableId(buf *bytes.Buffer) {
+	buf.Write([]byte{testTableId, 0, 0, 0, 0, 0})
+}
+
+// A table map for test.t with the given column types and metadata. The
+// column count must fit in a single byte packed integer.
+func tableMapTestEvent(columnTypes []byte, metadata []byte) testEvent {
+	payload := new(bytes.Buffer)
+	writeTestTableId(payload)
+	payload.Write([]byte{0, 0}) // reserved
+	payload.Write([]byte{4, 't', 'e', 's', 't', 0})
+	payload.Write([]byte{1, 't', 0})
+	payload.WriteByte(byte(len(columnTypes)))
+	payload.Write(columnTypes)
+	payload.WriteByte(byte(len(metadata)))
+	payload.Write(metadata)
+	payload.Write(make([]byte, (len(columnTypes) + 7) / 8)) // can be null
+
+	return testEvent{TABLE_MAP_EVENT, payload.Bytes()}
+}
+
+// A v2 rows event for the test table, with no extra data. rows is the
+// already encoded row images.
+func rowsTestEvent(typeCode byte, numberOfColumns int, usedSet []byte, rows ...[]byte) testEvent {
+	return rowsTestEventWithExtraData(typeCode, nil, numberOfColumns, usedSet, rows...)
+}
+
+func rowsTestEventWithExtraData(typeCode byte, extraData []byte, numberOfColumns int, usedSet []byte, rows ...[]byte) testEvent {
+	payload := new(bytes.Buffer)
+	writeTestTableId(payload)
+	payload.Write([]byte{0, 0}) // flags
+	binary.Write(payload, binary.LittleEndian, uint16(2 + len(extraData)))
+	payload.Write(extraData)
+	payload.WriteByte(byte(numberOfColumns))
+	payload.Write(usedSet)
+
+	for _, row := range rows {
+		payload.Write(row)
+	}
+
+	return testEvent{typeCode, payload.Bytes()}
+}
+
+func signedCell(mysqlType byte, v uint64) NumberRowImageCell {
+	return NewNumberRowImageCell(mysqlType, v, false)
+}
+
+func readRowsTestEvent(t *testing.T, events ...testEvent) *RowsEvent {
+	b := openTestBinlog(t, makeChecksummedTestBinlog(events...))
+
+	for range events[:len(events) - 1] {
+		_, err := b.NextEvent()
+		checkErr(t, err)
+	}
+
+	event, err := b.NextEvent()
+	checkErr(t, err)
+
+	if event == nil {
+		return nil
+	}
+
+	return event.Data().(*RowsEvent)
+}
+
+func TestRowsEventMultipleRows(t *testing.T) {
+	e := readRowsTestEvent(t,
+		tableMapTestEvent([]byte{MYSQL_TYPE_LONG, MYSQL_TYPE_TINY}, nil),
+		rowsTestEvent(WRITE_ROWS_EVENTv2, 2, []byte{0x03},
+			[]byte{0x00, 1, 0, 0, 0, 10},
+			[]byte{0x02, 2, 0, 0, 0}, // second column null
+			[]byte{0x00, 3, 0, 0, 0, 30},
+		),
+	)
+
+	if assert.NotNil(t, e) {
+		assert.Equal(t, []RowImage{
+			{signedCell(MYSQL_TYPE_LONG, 1), signedCell(MYSQL_TYPE_TINY, 10)},
+			{signedCell(MYSQL_TYPE_LONG, 2), NewNullRowImageCell(MYSQL_TYPE_TINY)},
+			{signedCell(MYSQL_TYPE_LONG, 3), signedCell(MYSQL_TYPE_TINY, 30)},
+		}, e.Rows)
+	}
+}
+
+func TestRowsEventBlobColumn(t *testing.T) {
+	// The BLOB's contents must be consumed for the next column and row to
+	// line up
+	e := readRowsTestEvent(t,
+		tableMapTestEvent([]byte{MYSQL_TYPE_BLOB, MYSQL_TYPE_TINY}, []byte{2}),
+		rowsTestEvent(WRITE_ROWS_EVENTv2, 2, []byte{0x03},
+			[]byte{0x00, 3, 0, 'a', 'b', 'c', 7},
+			[]byte{0x00, 0, 0, 8},
+			[]byte{0x01, 9}, // BLOB null
+		),
+	)
+
+	if assert.NotNil(t, e) {
+		assert.Equal(t, []RowImage{
+			{BlobRowImageCell("abc"), signedCell(MYSQL_TYPE_TINY, 7)},
+			{BlobRowImageCell{}, signedCell(MYSQL_TYPE_TINY, 8)},
+			{NewNullRowImageCell(MYSQL_TYPE_BLOB), signedCell(MYSQL_TYPE_TINY, 9)},
+		}, e.Rows)
+	}
+}
+
+func TestRowsEventPartialImage(t *testing.T) {
+	// Only the second and third columns are present, so the null
+	// bitfield has a bit for each of those two alone
+	e := readRowsTestEvent(t,
+		tableMapTestEvent([]byte{MYSQL_TYPE_LONG, MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT}, nil),
+		rowsTestEvent(DELETE_ROWS_EVENTv2, 3, []byte{0x06},
+			[]byte{0x01, 7, 0},
+			[]byte{0x00, 8, 9, 0},
+		),
+	)
+
+	if assert.NotNil(t, e) {
+		assert.Equal(t, []RowImage{
+			{nil, NewNullRowImageCell(MYSQL_TYPE_TINY), signedCell(MYSQL_TYPE_SHORT, 7)},
+			{nil, signedCell(MYSQL_TYPE_TINY, 8), signedCell(MYSQL_TYPE_SHORT, 9)},
+		}, e.Rows)
+	}
+}
+
+func TestRowsEventNoRows(t *testing.T) {
+	e := readRowsTestEvent(t,
+		tableMapTestEvent([]byte{MYSQL_TYPE_LONG}, nil),
+		rowsTestEvent(WRITE_ROWS_EVENTv2, 1, []byte{0x01}),
+	)
+
+	if assert.NotNil(t, e) {
+		assert.Empty(t, e.Rows)
+	}
+}
+
+func TestRowsEventOverrun(t *testing.T) {
+	b := openTestBinlog(t, makeChecksummedTestBinlog(
+		tableMapTestEvent([]byte{MYSQL_TYPE_LONG}, nil),
+		rowsTestEvent(WRITE_ROWS_EVENTv2, 1, []byte{0x01},
+			[]byte{0x00, 1, 0, 0, 0},
+			[]byte{0x00, 2, 0}, // cut short
+		),
+	))
+
+	_, err := b.NextEvent()
+	checkErr(t, err)
+
+	_, err = b.NextEvent()
+
+	var corrupt *ErrCorruptEvent
+	if assert.True(t, errors.As(err, &corrupt)) {
+		assert.Equal(t, byte(WRITE_ROWS_EVENTv2), corrupt.EventType)
+		assert.Contains(t, corrupt.Error(), "row 1 of table 42 runs past the end of the event")
+	}
+}
+
+// Same as rowsTestEvent, with the after image used bitfield for updates
+func updateRowsTestEvent(numberOfColumns int, beforeUsedSet, afterUsedSet []byte, rows ...[]byte) testEvent {
+	return rowsTestEvent(UPDATE_ROWS_EVENTv2, numberOfColumns, append(beforeUsedSet, afterUsedSet...), rows...)
+}
+
+func TestUpdateRowsEvent(t *testing.T) {
+	b := openTestBinlog(t, makeChecksummedTestBinlog(
+		tableMapTestEvent([]byte{MYSQL_TYPE_LONG, MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT}, nil),
+		// binlog_row_image=MINIMAL style: the primary key before, the
+		// changed columns after
+		updateRowsTestEvent(3, []byte{0x01}, []byte{0x06},
+			[]byte{0x00, 1, 0, 0, 0}, []byte{0x00, 5, 6, 0},
+			[]byte{0x00, 2, 0, 0, 0}, []byte{0x01, 7, 0},
+		),
+		updateRowsTestEvent(3, []byte{0x07}, []byte{0x07},
+			[]byte{0x00, 3, 0, 0, 0, 1, 2, 0}, []byte{0x02, 3, 0, 0, 0, 4, 0},
+		),
+	))
+
+	_, err := b.NextEvent()
+	checkErr(t, err)
+
+	event, err := b.NextEvent()
+	checkErr(t, err)
+
+	e, ok := event.Data().(*UpdateRowsEvent)
+	if assert.True(t, ok) {
+		assert.Equal(t, uint64(testTableId), e.TableId)
+		assert.Equal(t, []RowChange{
+			{
+				Before: RowImage{signedCell(MYSQL_TYPE_LONG, 1), nil, nil},
+				After:  RowImage{nil, signedCell(MYSQL_TYPE_TINY, 5), signedCell(MYSQL_TYPE_SHORT, 6)},
+			},
+			{
+				Before: RowImage{signedCell(MYSQL_TYPE_LONG, 2), nil, nil},
+				After:  RowImage{nil, NewNullRowImageCell(MYSQL_TYPE_TINY), signedCell(MYSQL_TYPE_SHORT, 7)},
+			},
+		}, e.Rows)
+		assert.Empty(t, e.Rows[0].ChangedColumns())
+	}
+
+	event, err = b.NextEvent()
+	checkErr(t, err)
+
+	e, ok = event.Data().(*UpdateRowsEvent)
+	if assert.True(t, ok) && assert.Len(t, e.Rows, 1) {
+		assert.Equal(t, []int{1, 2}, e.Rows[0].ChangedColumns())
+	}
+}
+
+func TestUpdateRowsEventMissingAfterImage(t *testing.T) {
+	b := openTestBinlog(t, makeChecksummedTestBinlog(
+		tableMapTestEvent([]byte{MYSQL_TYPE_LONG}, nil),
+		updateRowsTestEvent(1, []byte{0x01}, []byte{0x01}, []byte{0x00, 1, 0, 0, 0}),
+	))
+
+	_, err := b.NextEvent()
+	checkErr(t, err)
+
+	_, err = b.NextEvent()
+	assert.ErrorContains(t, err, "row 0 of table 42 runs past the end of the event")
+}
+
+func TestRowsEventKind(t *testing.T) {
+	v1 := func(typeCode byte) testEvent {
+		payload := new(bytes.Buffer)
+		writeTestTableId(payload)
+		binary.Write(payload, binary.LittleEndian, STMT_END_F | COMPLETE_ROWS_F)
+		payload.Write([]byte{1, 0x01}) // columns, used set
+		return testEvent{typeCode, payload.Bytes()}
+	}
+
+	v2 := rowsTestEvent(DELETE_ROWS_EVENTv2, 1, []byte{0x01})
+	v2.payload[6] = byte(NO_FOREIGN_KEY_CHECKS_F | RELAXED_UNIQUE_CHECKS_F)
+
+	b := openTestBinlog(t, makeChecksummedTestBinlog(
+		tableMapTestEvent([]byte{MYSQL_TYPE_LONG}, nil),
+		v1(WRITE_ROWS_EVENTv1),
+		v2,
+		updateRowsTestEvent(1, []byte{0x01}, []byte{0x01}),
+	))
+
+	_, err := b.NextEvent()
+	checkErr(t, err)
+
+	event, err := b.NextEvent()
+	checkErr(t, err)
+
+	if e, ok := event.Data().(*RowsEvent); assert.True(t, ok) {
+		assert.Equal(t, WRITE_ROWS_EVENTv1, e.Type())
+		assert.Equal(t, ROWS_OPERATION_INSERT, e.Operation)
+		assert.Equal(t, 1, e.Version)
+		assert.True(t, e.StatementEnd())
+		assert.True(t, e.CompleteRows())
+		assert.False(t, e.NoForeignKeyChecks())
+		assert.False(t, e.RelaxedUniqueChecks())
+	}
+
+	event, err = b.NextEvent()
+	checkErr(t, err)
+
+	if e, ok := event.Data().(*RowsEvent); assert.True(t, ok) {
+		assert.Equal(t, DELETE_ROWS_EVENTv2, e.Type())
+		assert.Equal(t, ROWS_OPERATION_DELETE, e.Operation)
+		assert.Equal(t, 2, e.Version)
+		assert.False(t, e.StatementEnd())
+		assert.False(t, e.CompleteRows())
+		assert.True(t, e.NoForeignKeyChecks())
+		assert.True(t, e.RelaxedUniqueChecks())
+	}
+
+	event, err = b.NextEvent()
+	checkErr(t, err)
+
+	if e, ok := event.Data().(*UpdateRowsEvent); assert.True(t, ok) {
+		assert.Equal(t, UPDATE_ROWS_EVENTv2, e.Type())
+		assert.Equal(t, ROWS_OPERATION_UPDATE, e.Operation)
+		assert.Equal(t, "UPDATE", e.Operation.String())
+		assert.Equal(t, 2, e.Version)
+	}
+}
+
+func TestRowsEventExtraData(t *testing.T) {
+	ndb := []byte{EXTRA_ROW_INFO_TYPECODE_NDB, 5, 1, 0xaa, 0xbb, 0xcc}
+	part := []byte{EXTRA_ROW_INFO_TYPECODE_PART, 3, 0}
+	updatePart := []byte{EXTRA_ROW_INFO_TYPECODE_PART, 3, 0, 1, 1}
+
+	b := openTestBinlog(t, makeChecksummedTestBinlog(
+		tableMapTestEvent([]byte{MYSQL_TYPE_TINY}, nil),
+		rowsTestEvent(WRITE_ROWS_EVENTv2, 1, []byte{0x01}, []byte{0x00, 1}),
+		rowsTestEventWithExtraData(WRITE_ROWS_EVENTv2, append(append([]byte{}, ndb...), part...), 1, []byte{0x01}, []byte{0x00, 2}),
+		rowsTestEventWithExtraData(UPDATE_ROWS_EVENTv2, updatePart, 1, []byte{0x01, 0x01}, []byte{0x00, 3}, []byte{0x00, 4}),
+		// An unknown record ends the decoding
+		rowsTestEventWithExtraData(DELETE_ROWS_EVENTv2, append([]byte{9, 9, 9}, part...), 1, []byte{0x01}, []byte{0x00, 5}),
+	))
+
+	_, err := b.NextEvent()
+	checkErr(t, err)
+
+	event, err := b.NextEvent()
+	checkErr(t, err)
+
+	if e, ok := event.Data().(*RowsEvent); assert.True(t, ok) {
+		assert.Equal(t, &RowsExtraData{Raw: []byte{}}, e.ExtraData)
+	}
+
+	event, err = b.NextEvent()
+	checkErr(t, err)
+
+	if e, ok := event.Data().(*RowsEvent); assert.True(t, ok) {
+		assert.Equal(t, &NdbRowInfo{Format: 1, Data: []byte{0xaa, 0xbb, 0xcc}}, e.ExtraData.Ndb)
+		assert.Equal(t, &PartitionRowInfo{PartitionId: 3}, e.ExtraData.Partition)
+		assert.Equal(t, []RowImage{{signedCell(MYSQL_TYPE_TINY, 2)}}, e.Rows)
+	}
+
+	event, err = b.NextEvent()
+	checkErr(t, err)
+
+	if e, ok := event.Data().(*UpdateRowsEvent); assert.True(t, ok) {
+		source := uint16(257)
+		assert.Nil(t, e.ExtraData.Ndb)
+		assert.Equal(t, &PartitionRowInfo{PartitionId: 3, SourcePartitionId: &source}, e.ExtraData.Partition)
+		assert.Len(t, e.Rows, 1)
+	}
+
+	event, err = b.NextEvent()
+	checkErr(t, err)
+
+	if e, ok := event.Data().(*RowsEvent); assert.True(t, ok) {
+		assert.Nil(t, e.ExtraData.Partition)
+		assert.Equal(t, 6, len(e.ExtraData.Raw))
+		assert.Equal(t, []RowImage{{signedCell(MYSQL_TYPE_TINY, 5)}}, e.Rows)
+	}
+}
+
+func TestRowsEventBadExtraData(t *testing.T) {
+	for _, extra := range [][]byte{
+		{EXTRA_ROW_INFO_TYPECODE_NDB, 1, 0},
+		{EXTRA_ROW_INFO_TYPECODE_NDB, 9, 0, 1},
+		{EXTRA_ROW_INFO_TYPECODE_PART, 1},
+	} {
+		b := openTestBinlog(t, makeChecksummedTestBinlog(
+			tableMapTestEvent([]byte{MYSQL_TYPE_TINY}, nil),
+			rowsTestEventWithExtraData(WRITE_ROWS_EVENTv2, extra, 1, []byte{0x01}, []byte{0x00, 1}),
+		))
+
+		_, err := b.NextEvent()
+		checkErr(t, err)
+
+		_, err = b.NextEvent()
+		assert.Error(t, err, "%v", extra)
+	}
+}
@@ -0,0 +1,139 @@
+package binlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Extra row info type codes
+const (
+	EXTRA_ROW_INFO_TYPECODE_NDB  byte = 0
+	EXTRA_ROW_INFO_TYPECODE_PART byte = 1
+)
+
+// Length of the NDB info header (the length and format bytes)
+const EXTRA_ROW_INFO_HDR_BYTES = 2
+
+// Information passed along by the NDB cluster engine
+type NdbRowInfo struct {
+	Format byte
+	Data   []byte
+}
+
+// The partition the rows belong to, for partitioned tables (MySQL 8.0+)
+type PartitionRowInfo struct {
+	PartitionId uint16
+	// Only set for update events: the partition the row was in before
+	// the update, which differs from PartitionId when it moved
+	SourcePartitionId *uint16
+}
+
+// The decoded extra data section of a v2 rows event. Any record that
+// wasn't present is nil.
+type RowsExtraData struct {
+	Ndb       *NdbRowInfo
+	Partition *PartitionRowInfo
+	Raw       []byte // the whole section, less its length
+}
+
+/*
+ROWS EVENT EXTRA DATA
+=====================
+
+Only in v2 rows events, right after the flags:
+
+2 bytes = length of the section, including these 2 bytes
+
+followed by any number of records, each a 1 byte type code and then:
+
+EXTRA_ROW_INFO_TYPECODE_NDB:
+1 byte  = length of the record, including this and the format byte
+1 byte  = format
+L bytes = data (L = length - EXTRA_ROW_INFO_HDR_BYTES)
+
+EXTRA_ROW_INFO_TYPECODE_PART:
+2 bytes = partition id
+update events only:
+2 bytes = source partition id
+
+A record of an unknown type ends the decoding, since its length can't
+be known. The undecoded bytes remain available in Raw.
+
+*/
+
+func readRowsExtraData(r io.Reader, operation RowsOperation) (*RowsExtraData, error) {
+	length, err := ReadUint16(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if length < 2 {
+		return nil, fmt.Errorf("rows event extra data length %v is too short", length)
+	}
+
+	raw, err := ReadBytes(r, int(length - 2))
+	if err != nil {
+		return nil, err
+	}
+
+	extra := &RowsExtraData{Raw: raw}
+	reader := bytes.NewReader(raw)
+
+	for reader.Len() > 0 {
+		typeCode, err := ReadByte(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		switch typeCode {
+		case EXTRA_ROW_INFO_TYPECODE_NDB:
+			recordLength, err := ReadUint8(reader)
+			if err != nil {
+				return nil, err
+			}
+
+			if recordLength < EXTRA_ROW_INFO_HDR_BYTES {
+				return nil, fmt.Errorf("NDB extra row info length %v is too short", recordLength)
+			}
+
+			info := new(NdbRowInfo)
+
+			info.Format, err = ReadByte(reader)
+			if err != nil {
+				return nil, err
+			}
+
+			info.Data, err = ReadBytes(reader, int(recordLength - EXTRA_ROW_INFO_HDR_BYTES))
+			if err != nil {
+				return nil, fmt.Errorf("NDB extra row info runs past the extra data: %v", err)
+			}
+
+			extra.Ndb = info
+
+		case EXTRA_ROW_INFO_TYPECODE_PART:
+			info := new(PartitionRowInfo)
+
+			info.PartitionId, err = ReadUint16(reader)
+			if err != nil {
+				return nil, fmt.Errorf("partition extra row info runs past the extra data: %v", err)
+			}
+
+			if operation == ROWS_OPERATION_UPDATE {
+				source, err := ReadUint16(reader)
+				if err != nil {
+					return nil, fmt.Errorf("partition extra row info runs past the extra data: %v", err)
+				}
+
+				info.SourcePartitionId = &source
+			}
+
+			extra.Partition = info
+
+		default:
+			return extra, nil
+		}
+	}
+
+	return extra, nil
+}
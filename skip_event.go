@@ -1,7 +1,6 @@
-package main
+package binlog
 
 import (
-	"fmt"
 	"io"
 )
 
@@ -9,10 +8,13 @@ type SkipEvent struct {}
 
 type SkipEventDeserializer struct {}
 
-func (d *SkipEventDeserializer) Deserialize(reader io.ReadSeeker, header *EventHeader) EventData {
-	// Inefficiency on large buffers?
-	reader.Seek(int64(header.NextPosition), 0)
-	fmt.Println("Skipping to", header.NextPosition)
+// The payload has already been read off the log by ReadEvent,
+// so there is nothing left to do but ignore it
+func (d *SkipEventDeserializer) Deserialize(b *Binlog, reader io.ReadSeeker, header *EventHeader) (EventData, error) {
+	b.logger.Log(LOG_LEVEL_DEBUG, "Unsupported event data deserialization, skipping",
+		Field("type", header.Type),
+		Field("next_position", header.NextPosition),
+	)
 
-	return &SkipEvent{}
+	return &SkipEvent{}, nil
 }
@@ -1,13 +1,4 @@
-package main
-
-var tableMapCollectionInstance TableMapCollection
+package binlog
 
+// Table maps are kept per Binlog, see Binlog.TableMaps
 type TableMapCollection map[uint64]*TableMapEvent
-
-func GetTableMapCollectionInstance() TableMapCollection {
-	if tableMapCollectionInstance == nil {
-		tableMapCollectionInstance = make(TableMapCollection)
-	}
-
-	return tableMapCollectionInstance
-}
@@ -1,9 +1,8 @@
-package main
+package binlog
 
 import (
-	"io"
-	"log"
 	"fmt"
+	"io"
 )
 
 type TableMapEvent struct {
@@ -14,6 +13,35 @@ type TableMapEvent struct {
 	ColumnTypes     []byte
 	Metadata        []*ColumnMetadata
 	CanBeNull       Bitset
+	// nil unless the server wrote any (MySQL 8.0+)
+	OptionalMetadata *TableMapOptionalMetadata
+
+	unsigned []bool
+}
+
+// Column information for tables logged by servers that don't write it
+// into the table map (before MySQL 8.0)
+type TableSchema struct {
+	Unsigned []bool // indexed by column, true for UNSIGNED columns
+}
+
+// Table schemas keyed by "database.table"
+type Schema map[string]*TableSchema
+
+// Reports whether an integer column is UNSIGNED, going by the optional
+// metadata or else the Schema the Binlog was given. Columns neither
+// covers are reported as signed, the MySQL default; their cells still
+// give the unsigned value through Uint64.
+func (e *TableMapEvent) IsUnsigned(column int) bool {
+	return column < len(e.unsigned) && e.unsigned[column]
+}
+
+func (e *TableMapEvent) resolveSignedness(schema Schema) {
+	if e.OptionalMetadata != nil && e.OptionalMetadata.Unsigned != nil {
+		e.unsigned = e.OptionalMetadata.Unsigned
+	} else if table, ok := schema[e.DatabaseName + "." + e.TableName]; ok && table != nil {
+		e.unsigned = table.Unsigned
+	}
 }
 
 type TableMapEventDeserializer struct {}
@@ -22,8 +50,8 @@ type TableMapEventDeserializer struct {}
 TABLE MAP DATA
 ==============
 
-Fixed:
-6 bytes = table id
+Fixed (post-header):
+6 bytes = table id (4 bytes if the post-header is 6 bytes long)
 2 bytes = reserved (skip)
 
 Let:
@@ -46,123 +74,120 @@ C bytes   = column types
 P bytes   = metdata length 
 M bytes   = metadata (skipping for now)
 N bytes   = can be null bitset
+rest      = optional metadata (see table_map_optional_metadata.go)
 
 */
 
-func (d *TableMapEventDeserializer) Deserialize(reader io.ReadSeeker, header *EventHeader) EventData {
-	fmt.Println("Expected next pos: ", header.NextPosition)
-
+func (d *TableMapEventDeserializer) Deserialize(b *Binlog, reader io.ReadSeeker, header *EventHeader) (EventData, error) {
 	e := new(TableMapEvent)
 
 	var err error
 
-	e.TableId, err = ReadTableId(reader)
-	fatalErr(err)
-
-	_, err = reader.Seek(3, 1) // Skip 2 reserved and 1 database name length bytes
-	fatalErr(err)
-
-	e.DatabaseName, err = ReadNullTerminatedString(reader)
-	fatalErr(err)
-
-	_, err = reader.Seek(1, 1) // Skip table name length
-	fatalErr(err)
-
-	e.TableName, err = ReadNullTerminatedString(reader)
-	fatalErr(err)
-
-	/*
-	OLD STYLE
-	=========
+	postHeaderLength := int(b.formatDescription.PostHeaderLength(header.Type))
+	tableIdLength := b.formatDescription.tableIdLength(header.Type)
 
-	var nullTerm uint8
-
-	_, err := reader.Seek(2, 1) // reserved
-	fatalErr(err)
-
-	databaseNameLength, err := ReadUint8(reader)
-	fatalErr(err)
-
-	databaseNameBytes, err := ReadBytes(reader, int(databaseNameLength))
-	fatalErr(err)
-
-	// String null terminator
-	nullTerm, err = ReadUint8(reader)
-	if nullTerm != 0 {
-		log.Fatal("expected null terminator")
+	if postHeaderLength < tableIdLength + 2 {
+		return nil, fmt.Errorf("table map post-header length %v is too short", postHeaderLength)
 	}
 
-	e.DatabaseName = string(databaseNameBytes)
+	e.TableId, err = ReadTableId(reader, tableIdLength)
+	if err != nil {
+		return nil, err
+	}
 
-	tableNameLength, err := ReadUint8(reader)
-	fatalErr(err)
+	// Skip the 2 reserved bytes (and anything else a newer server put in
+	// the post-header), plus 1 database name length byte
+	if _, err = reader.Seek(int64(postHeaderLength - tableIdLength + 1), 1); err != nil {
+		return nil, err
+	}
 
-	tableNameBytes, err := ReadBytes(reader, int(tableNameLength))
-	fatalErr(err)
+	e.DatabaseName, err = ReadNullTerminatedString(reader)
+	if err != nil {
+		return nil, err
+	}
 
-	// String null terminator
-	nullTerm, err = ReadUint8(reader)
-	if nullTerm != 0 {
-		log.Fatal("expected null terminator")
+	if _, err = reader.Seek(1, 1); err != nil { // Skip table name length
+		return nil, err
 	}
 
-	e.TableName = string(tableNameBytes)
-	*/
+	e.TableName, err = ReadNullTerminatedString(reader)
+	if err != nil {
+		return nil, err
+	}
 
 	e.NumberOfColumns, err = ReadPackedInteger(reader)
-	fatalErr(err)
+	if err != nil {
+		return nil, err
+	}
 
 	e.ColumnTypes, err = ReadBytes(reader, int(e.NumberOfColumns))
-	fatalErr(err)
+	if err != nil {
+		return nil, err
+	}
 
 	metadataLength, err := ReadPackedInteger(reader)
-	fatalErr(err)
-
-	// skip for now
-	// fatalErr(reader.Seek(metadataLength, 1))
+	if err != nil {
+		return nil, err
+	}
 
 	// This represents how much we have read to make sure we don't over read
 	metadataRead := uint64(0)
 	metadata := make([]*ColumnMetadata, len(e.ColumnTypes))
 
 	for i, t :=  range e.ColumnTypes {
-		metadata[i] = DeserializeColomnMetadata(reader, t)
+		metadata[i], err = DeserializeColomnMetadata(reader, t)
+		if err != nil {
+			return nil, err
+		}
 
 		if metadata[i] != nil {
 			metadataRead += uint64(len(metadata[i].data))
 		}
 
 		if metadataRead > metadataLength {
-			log.Fatal("Exceeded metadata length while processing metadata")
+			return nil, fmt.Errorf("exceeded metadata length while processing metadata (%v > %v)", metadataRead, metadataLength)
 		}
 	}
 
-	fmt.Printf("read: %v, total: %v\n", metadataRead, metadataLength)
-
 	e.Metadata = metadata
 
 	e.CanBeNull, err = ReadBitset(reader, int(e.NumberOfColumns))
-	fatalErr(err)
+	if err != nil {
+		return nil, err
+	}
 
-	// Insert into tableMapCollectionInstance
-	mapCollection := GetTableMapCollectionInstance()
-	if _, ok := mapCollection[e.TableId]; !ok {
-		mapCollection[e.TableId] = e
+	if err = e.deserializeOptionalMetadata(reader); err != nil {
+		return nil, err
 	}
 
-	n, err := reader.Seek(0, 1)
-	fatalErr(err)
-	fmt.Println("Actual next pos:", n)
-	fmt.Println("vardump:", *e)
+	e.resolveSignedness(b.schema)
 
-	lastBytes, err := ReadBytes(reader, 4)
-	fmt.Println("Remaining bytes:", lastBytes)
+	// Keep track of the table so rows events can find it, once the whole
+	// event has been read so a bad one is never used
+	if _, ok := b.tableMaps[e.TableId]; !ok {
+		b.tableMaps[e.TableId] = e
+	}
 
-	for _, m := range e.Metadata {
+	b.logger.Log(LOG_LEVEL_DEBUG, "Table map event",
+		Field("table_id", e.TableId),
+		Field("database", e.DatabaseName),
+		Field("table", e.TableName),
+		Field("columns", e.NumberOfColumns),
+		Field("column_types", e.ColumnTypes),
+		Field("metadata_read", metadataRead),
+		Field("metadata_length", metadataLength),
+		Field("optional_metadata", e.OptionalMetadata != nil),
+	)
+
+	for i, m := range e.Metadata {
 		if m != nil {
-			fmt.Println(*m)
+			b.logger.Log(LOG_LEVEL_DEBUG, "Column metadata",
+				Field("table_id", e.TableId),
+				Field("column", i),
+				Field("metadata", *m),
+			)
 		}
 	}
 
-	return e
+	return e, nil
 }
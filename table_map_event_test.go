@@ -0,0 +1,149 @@
+package binlog
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readTableMapTestEvent(t *testing.T, e testEvent) (*TableMapEvent, error) {
+	b := openTestBinlog(t, makeChecksummedTestBinlog(e))
+
+	event, err := b.NextEvent()
+	if err != nil {
+		return nil, err
+	}
+
+	return event.Data().(*TableMapEvent), nil
+}
+
+func TestTableMapWithoutOptionalMetadata(t *testing.T) {
+	e, err := readTableMapTestEvent(t, tableMapTestEvent([]byte{MYSQL_TYPE_LONG, MYSQL_TYPE_VARCHAR}, []byte{20, 0}))
+	checkErr(t, err)
+
+	if assert.NotNil(t, e) {
+		assert.Equal(t, "test", e.DatabaseName)
+		assert.Equal(t, "t", e.TableName)
+		assert.Nil(t, e.OptionalMetadata)
+	}
+}
+
+func TestTableMapOptionalMetadata(t *testing.T) {
+	e := tableMapTestEvent(
+		[]byte{
+			MYSQL_TYPE_LONG, MYSQL_TYPE_TINY, MYSQL_TYPE_VARCHAR, MYSQL_TYPE_STRING,
+			MYSQL_TYPE_STRING, MYSQL_TYPE_GEOMETRY, MYSQL_TYPE_STRING,
+		},
+		[]byte{
+			20, 0,                 // VARCHAR(20)
+			MYSQL_TYPE_ENUM, 1,    // ENUM
+			MYSQL_TYPE_SET, 1,     // SET
+			4,                     // GEOMETRY
+			MYSQL_TYPE_STRING, 10, // CHAR(10)
+		},
+	)
+
+	optional := new(bytes.Buffer)
+	field := func(fieldType byte, value ...byte) {
+		optional.WriteByte(fieldType)
+		optional.WriteByte(byte(len(value)))
+		optional.Write(value)
+	}
+
+	field(SIGNEDNESS, 0x80)
+	field(DEFAULT_CHARSET, 224, 1, 63)
+	field(ENUM_AND_SET_COLUMN_CHARSET, 8, 33)
+	field(COLUMN_NAME, 2, 'i', 'd', 1, 'n', 1, 's', 1, 'e', 2, 's', 't', 1, 'g', 1, 'c')
+	field(SET_STR_VALUE, 2, 1, 'a', 1, 'b')
+	field(ENUM_STR_VALUE, 1, 1, 'x')
+	field(GEOMETRY_TYPE, 1)
+	field(99, 1, 2, 3) // unknown, skipped
+	field(PRIMARY_KEY_WITH_PREFIX, 0, 0, 2, 10)
+	field(COLUMN_VISIBILITY, 0xfc)
+
+	e.payload = append(e.payload, optional.Bytes()...)
+
+	tableMap, err := readTableMapTestEvent(t, e)
+	checkErr(t, err)
+
+	if assert.NotNil(t, tableMap) && assert.NotNil(t, tableMap.OptionalMetadata) {
+		assert.Equal(t, &TableMapOptionalMetadata{
+			Unsigned:      []bool{true, false, false, false, false, false, false},
+			Collations:    []uint64{0, 0, 224, 8, 33, 0, 63},
+			ColumnNames:   []string{"id", "n", "s", "e", "st", "g", "c"},
+			SetValues:     [][]string{nil, nil, nil, nil, {"a", "b"}, nil, nil},
+			EnumValues:    [][]string{nil, nil, nil, {"x"}, nil, nil, nil},
+			GeometryTypes: []uint64{0, 0, 0, 0, 0, 1, 0},
+			PrimaryKey:    []PrimaryKeyColumn{{0, 0}, {2, 10}},
+			Visible:       []bool{true, true, true, true, true, true, false},
+		}, tableMap.OptionalMetadata)
+	}
+}
+
+func TestTableMapSimplePrimaryKeyAndColumnCharset(t *testing.T) {
+	e := tableMapTestEvent([]byte{MYSQL_TYPE_VARCHAR, MYSQL_TYPE_LONG, MYSQL_TYPE_BLOB}, []byte{20, 0, 2})
+	e.payload = append(e.payload,
+		COLUMN_CHARSET, 2, 45, 63,
+		SIMPLE_PRIMARY_KEY, 2, 1, 0,
+	)
+
+	tableMap, err := readTableMapTestEvent(t, e)
+	checkErr(t, err)
+
+	if assert.NotNil(t, tableMap) && assert.NotNil(t, tableMap.OptionalMetadata) {
+		assert.Equal(t, []uint64{45, 0, 63}, tableMap.OptionalMetadata.Collations)
+		assert.Equal(t, []PrimaryKeyColumn{{1, 0}, {0, 0}}, tableMap.OptionalMetadata.PrimaryKey)
+		assert.Nil(t, tableMap.OptionalMetadata.ColumnNames)
+	}
+}
+
+func TestTableMapBadOptionalMetadata(t *testing.T) {
+	for _, optional := range [][]byte{
+		{COLUMN_NAME, 5, 1, 'a'},       // runs past the end
+		{SIMPLE_PRIMARY_KEY, 1, 9},     // no such column
+		{DEFAULT_CHARSET, 3, 8, 4, 33}, // no such character column
+		{COLUMN_NAME, 2, 5, 'a'},       // name runs past the field
+	} {
+		e := tableMapTestEvent([]byte{MYSQL_TYPE_VARCHAR}, []byte{20, 0})
+		e.payload = append(e.payload, optional...)
+
+		b := openTestBinlog(t, makeChecksummedTestBinlog(e))
+
+		_, err := b.NextEvent()
+		assert.Error(t, err, "%v", optional)
+
+		// A table map that failed to parse must not be used by rows events
+		assert.Empty(t, b.TableMaps(), "%v", optional)
+	}
+}
+
+func TestTableMapCorruptCounts(t *testing.T) {
+	for _, columnCount := range [][]byte{
+		{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+		{0xfd, 0xff, 0xff, 0xff},
+	} {
+		payload := new(bytes.Buffer)
+		writeTestTableId(payload)
+		payload.Write([]byte{0, 0})
+		payload.Write([]byte{4, 't', 'e', 's', 't', 0})
+		payload.Write([]byte{1, 't', 0})
+		payload.Write(columnCount)
+		payload.Write([]byte{MYSQL_TYPE_LONG, 0, 0})
+
+		_, err := readTableMapTestEvent(t, testEvent{TABLE_MAP_EVENT, payload.Bytes()})
+		assert.Error(t, err, "%x", columnCount)
+	}
+
+	for _, optional := range [][]byte{
+		{COLUMN_NAME, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		{COLUMN_NAME, 9, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+	} {
+		e := tableMapTestEvent([]byte{MYSQL_TYPE_VARCHAR}, []byte{20, 0})
+		e.payload = append(e.payload, optional...)
+
+		_, err := readTableMapTestEvent(t, e)
+		assert.Error(t, err, "%x", optional)
+	}
+}
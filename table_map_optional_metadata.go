@@ -0,0 +1,370 @@
+package binlog
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Optional metadata field types
+const (
+	SIGNEDNESS                   byte = 1 + iota
+	DEFAULT_CHARSET
+	COLUMN_CHARSET
+	COLUMN_NAME
+	SET_STR_VALUE
+	ENUM_STR_VALUE
+	GEOMETRY_TYPE
+	SIMPLE_PRIMARY_KEY
+	PRIMARY_KEY_WITH_PREFIX
+	ENUM_AND_SET_DEFAULT_CHARSET
+	ENUM_AND_SET_COLUMN_CHARSET
+	COLUMN_VISIBILITY
+)
+
+// A primary key column; PrefixLength is 0 unless only a prefix of the
+// column is indexed
+type PrimaryKeyColumn struct {
+	Column       int
+	PrefixLength uint64
+}
+
+// Table map metadata written by MySQL 8.0+. Which of the fields are
+// present depends on binlog_row_metadata (MINIMAL logs the signedness,
+// charsets and geometry types, FULL everything) and on the table; those
+// that weren't logged are nil.
+//
+// The per column slices are indexed by column, and hold a zero value for
+// columns the field doesn't apply to.
+type TableMapOptionalMetadata struct {
+	Unsigned      []bool     // numeric columns
+	Collations    []uint64   // character, ENUM and SET columns
+	ColumnNames   []string
+	SetValues     [][]string // SET columns
+	EnumValues    [][]string // ENUM columns
+	GeometryTypes []uint64   // GEOMETRY columns
+	PrimaryKey    []PrimaryKeyColumn
+	Visible       []bool
+}
+
+/*
+TABLE MAP OPTIONAL METADATA
+===========================
+
+Follows the can be null bitset, to the end of the event, as a sequence of
+
+1 byte  = field type (SIGNEDNESS etc.)
+1 byte  = packed int byte key (see ReadPackedInteger)
+P bytes = field length L
+L bytes = field value
+
+Some of the values cover only one kind of column, in column order:
+numeric (TINY, SHORT, INT24, LONG, LONGLONG, FLOAT, DOUBLE, NEWDECIMAL),
+character (VARCHAR, BLOB, and STRING/VAR_STRING that aren't ENUM or SET),
+ENUM and SET (STRING with an ENUM or SET real type), or GEOMETRY.
+
+SIGNEDNESS, COLUMN_VISIBILITY:
+a bitset, most significant bit first, with a bit per numeric column
+(1 = unsigned) or per column (1 = visible)
+
+DEFAULT_CHARSET, ENUM_AND_SET_DEFAULT_CHARSET:
+packed int = default collation
+any number of (
+	packed int = index among the character (or ENUM and SET) columns
+	packed int = collation of that column
+)
+
+COLUMN_CHARSET, ENUM_AND_SET_COLUMN_CHARSET:
+packed int collation per character (or ENUM and SET) column
+
+COLUMN_NAME:
+per column, packed int length followed by the name
+
+SET_STR_VALUE, ENUM_STR_VALUE:
+per SET (or ENUM) column, a packed int count and then that many packed
+int length prefixed strings
+
+GEOMETRY_TYPE:
+packed int geometry type per GEOMETRY column
+
+SIMPLE_PRIMARY_KEY:
+packed int column index per primary key column
+
+PRIMARY_KEY_WITH_PREFIX:
+per primary key column, packed int column index and packed int prefix
+length (0 for the whole column)
+
+Unknown field types are skipped.
+
+*/
+
+// Column kinds as far as the optional metadata is concerned
+const (
+	otherColumn = iota
+	numericColumn
+	characterColumn
+	enumColumn
+	setColumn
+	geometryColumn
+)
+
+// Returns the type a column really has. STRING columns keep their real
+// type (which may be ENUM or SET) in the metadata.
+func (e *TableMapEvent) columnRealType(column int) byte {
+	t := e.ColumnTypes[column]
+
+	if t != MYSQL_TYPE_STRING || e.Metadata[column] == nil {
+		return t
+	}
+
+	realType, err := e.Metadata[column].RealType()
+	if err != nil {
+		return t
+	}
+
+	return realType
+}
+
+func (e *TableMapEvent) columnKind(column int) int {
+	switch e.columnRealType(column) {
+	case MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_INT24, MYSQL_TYPE_LONG, MYSQL_TYPE_LONGLONG,
+	  MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE, MYSQL_TYPE_NEWDECIMAL:
+		return numericColumn
+
+	case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_BLOB, MYSQL_TYPE_VAR_STRING, MYSQL_TYPE_STRING:
+		return characterColumn
+
+	case MYSQL_TYPE_ENUM:
+		return enumColumn
+
+	case MYSQL_TYPE_SET:
+		return setColumn
+
+	case MYSQL_TYPE_GEOMETRY:
+		return geometryColumn
+	}
+
+	return otherColumn
+}
+
+// Returns the indexes of the columns of the given kinds, in order
+func (e *TableMapEvent) columnsOfKind(kinds ...int) []int {
+	columns := []int{}
+
+	for i := range e.ColumnTypes {
+		for _, kind := range kinds {
+			if e.columnKind(i) == kind {
+				columns = append(columns, i)
+				break
+			}
+		}
+	}
+
+	return columns
+}
+
+func (e *TableMapEvent) deserializeOptionalMetadata(r io.Reader) error {
+	m := new(TableMapOptionalMetadata)
+	numberOfColumns := len(e.ColumnTypes)
+
+	for fields := 0; ; fields++ {
+		fieldType, err := ReadByte(r)
+		if err == io.EOF {
+			// Servers before 8.0 write none
+			if fields > 0 {
+				e.OptionalMetadata = m
+			}
+
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		length, err := ReadPackedInteger(r)
+		if err != nil {
+			return err
+		}
+
+		value, err := ReadBytes(r, int(length))
+		if err != nil {
+			return fmt.Errorf("optional metadata field %v runs past the end of the event: %v", fieldType, err)
+		}
+
+		v := bytes.NewReader(value)
+
+		switch fieldType {
+		case SIGNEDNESS:
+			m.Unsigned = make([]bool, numberOfColumns)
+
+			for i, column := range e.columnsOfKind(numericColumn) {
+				if m.Unsigned[column], err = msbFirstBit(value, i); err != nil {
+					return err
+				}
+			}
+
+		case DEFAULT_CHARSET, COLUMN_CHARSET:
+			err = m.readCollations(v, fieldType == DEFAULT_CHARSET, e.columnsOfKind(characterColumn), numberOfColumns)
+
+		case ENUM_AND_SET_DEFAULT_CHARSET, ENUM_AND_SET_COLUMN_CHARSET:
+			err = m.readCollations(v, fieldType == ENUM_AND_SET_DEFAULT_CHARSET, e.columnsOfKind(enumColumn, setColumn), numberOfColumns)
+
+		case COLUMN_NAME:
+			m.ColumnNames = make([]string, numberOfColumns)
+
+			for i := range m.ColumnNames {
+				if m.ColumnNames[i], err = readPackedLengthString(v); err != nil {
+					return err
+				}
+			}
+
+		case SET_STR_VALUE:
+			m.SetValues, err = readStringValues(v, e.columnsOfKind(setColumn), numberOfColumns)
+
+		case ENUM_STR_VALUE:
+			m.EnumValues, err = readStringValues(v, e.columnsOfKind(enumColumn), numberOfColumns)
+
+		case GEOMETRY_TYPE:
+			m.GeometryTypes = make([]uint64, numberOfColumns)
+
+			for _, column := range e.columnsOfKind(geometryColumn) {
+				if m.GeometryTypes[column], err = ReadPackedInteger(v); err != nil {
+					return err
+				}
+			}
+
+		case SIMPLE_PRIMARY_KEY, PRIMARY_KEY_WITH_PREFIX:
+			m.PrimaryKey = []PrimaryKeyColumn{}
+
+			for v.Len() > 0 {
+				var key PrimaryKeyColumn
+
+				column, err := ReadPackedInteger(v)
+				if err != nil {
+					return err
+				}
+
+				if column >= uint64(numberOfColumns) {
+					return fmt.Errorf("primary key column %v out of range for %v columns", column, numberOfColumns)
+				}
+
+				key.Column = int(column)
+
+				if fieldType == PRIMARY_KEY_WITH_PREFIX {
+					if key.PrefixLength, err = ReadPackedInteger(v); err != nil {
+						return err
+					}
+				}
+
+				m.PrimaryKey = append(m.PrimaryKey, key)
+			}
+
+		case COLUMN_VISIBILITY:
+			m.Visible = make([]bool, numberOfColumns)
+
+			for i := range m.Visible {
+				if m.Visible[i], err = msbFirstBit(value, i); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Reads bit i of a bitset stored most significant bit first, unlike the
+// LSB first Bitset
+func msbFirstBit(b []byte, i int) (bool, error) {
+	if i / 8 >= len(b) {
+		return false, fmt.Errorf("bit %v out of range of a %v byte bitset", i, len(b))
+	}
+
+	return b[i / 8] & (0x80 >> uint(i % 8)) != 0, nil
+}
+
+func readPackedLengthString(r io.Reader) (string, error) {
+	length, err := ReadPackedInteger(r)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := ReadBytes(r, int(length))
+	return string(b), err
+}
+
+// Reads either form of the charset fields for the given columns. Fields
+// for character columns and for ENUM and SET columns fill in different
+// columns of the same slice.
+func (m *TableMapOptionalMetadata) readCollations(r *bytes.Reader, withDefault bool, columns []int, numberOfColumns int) error {
+	if m.Collations == nil {
+		m.Collations = make([]uint64, numberOfColumns)
+	}
+
+	if !withDefault {
+		for _, column := range columns {
+			collation, err := ReadPackedInteger(r)
+			if err != nil {
+				return err
+			}
+
+			m.Collations[column] = collation
+		}
+
+		return nil
+	}
+
+	defaultCollation, err := ReadPackedInteger(r)
+	if err != nil {
+		return err
+	}
+
+	for _, column := range columns {
+		m.Collations[column] = defaultCollation
+	}
+
+	for r.Len() > 0 {
+		index, err := ReadPackedInteger(r)
+		if err != nil {
+			return err
+		}
+
+		collation, err := ReadPackedInteger(r)
+		if err != nil {
+			return err
+		}
+
+		if index >= uint64(len(columns)) {
+			return fmt.Errorf("charset column index %v out of range for %v columns", index, len(columns))
+		}
+
+		m.Collations[columns[index]] = collation
+	}
+
+	return nil
+}
+
+func readStringValues(r io.Reader, columns []int, numberOfColumns int) ([][]string, error) {
+	values := make([][]string, numberOfColumns)
+
+	for _, column := range columns {
+		count, err := ReadPackedInteger(r)
+		if err != nil {
+			return nil, err
+		}
+
+		values[column] = []string{}
+
+		for i := uint64(0); i < count; i++ {
+			s, err := readPackedLengthString(r)
+			if err != nil {
+				return nil, err
+			}
+
+			values[column] = append(values[column], s)
+		}
+	}
+
+	return values, nil
+}
@@ -1,34 +1,65 @@
-package main
+package binlog
 
 import (
-	"bytes"
-	"encoding/binary"
 	"fmt"
 	"io"
 	"time"
 )
 
-func expandBitsetToBytesBigEndian(set Bitset, bitsetBitCount int) []byte {
-	byteArray := make([]byte, int((bitsetBitCount + 7) / 8))
+// A DATE or DATETIME as MySQL stores it. Unless NO_ZERO_IN_DATE is set
+// MySQL accepts dates like 2014-00-00, which a time.Time can't hold.
+type MysqlDatetime struct {
+	Year        int
+	Month       int
+	Day         int
+	Hour        int
+	Minute      int
+	Second      int
+	Microsecond int
+}
+
+// Reports whether this is 0000-00-00 00:00:00
+func (d MysqlDatetime) IsZero() bool {
+	return d == MysqlDatetime{}
+}
+
+// Reports whether the month or day is zero
+func (d MysqlDatetime) ZeroInDate() bool {
+	return d.Month == 0 || d.Day == 0
+}
+
+// Converts to a time.Time in UTC. The all zero value gives the zero
+// time.Time; any other value with a zero month or day has no time.Time
+// and gives an error rather than a different date.
+func (d MysqlDatetime) Time() (time.Time, error) {
+	if d.IsZero() {
+		return time.Time{}, nil
+	}
 
-	for i := uint(bitsetBitCount); i > 0 ; i-- {
-		if set.Bit(i) {
-			byteArray[int((i + 1) / 8)] |= 1 << i 
-		}
+	if d.ZeroInDate() {
+		return time.Time{}, fmt.Errorf("%v has a zero month or day", d)
 	}
 
-	return byteArray
+	return time.Date(d.Year, time.Month(d.Month), d.Day, d.Hour, d.Minute, d.Second, d.Microsecond * 1000, time.UTC), nil
 }
 
-func padBytesBigEndian(b []byte, count int) []byte {
-	padding := make([]byte, count)
-	for i := range padding {
-		padding[i] = NUL
+// Formats as MySQL does, with microseconds only if there are any
+func (d MysqlDatetime) String() string {
+	s := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", d.Year, d.Month, d.Day, d.Hour, d.Minute, d.Second)
+
+	if d.Microsecond != 0 {
+		s += fmt.Sprintf(".%06d", d.Microsecond)
 	}
 
-	return append(padding, b...)
+	return s
 }
 
+// Offsets added to the integer part of TIME V2 and DATETIME V2 values
+const (
+	TIMEF_INT_OFS     = 0x800000
+	DATETIMEF_INT_OFS = 0x8000000000
+)
+
 // We could do this with int((fsp + 1) / 2), but that is less clear
 func fractionalSecondsPackSize(fsp int) int {
 	switch fsp {
@@ -43,161 +74,340 @@ func fractionalSecondsPackSize(fsp int) int {
 	return 0
 }
 
-func readFractionalSeconds(r io.Reader, metadata *ColumnMetadata) (int32, error) {
-	packSize := fractionalSecondsPackSize(int(metadata.FractionalSecondsPrecision()))
+// What the stored fractional seconds are multiplied by to give
+// microseconds, for each pack size
+var fractionalSecondsScale = [...]int64{0, 10000, 100, 1}
+
+/*
+FRACTIONAL SECONDS
+==================
+
+Follow the integer part of the V2 types, taking (fsp + 1) / 2 bytes
+
+Big Endian
+
+fsp 1, 2 = hundredths of a second
+fsp 3, 4 = ten thousandths of a second
+fsp 5, 6 = microseconds
+
+*/
+
+// Reads the fractional seconds as stored, along with how many bytes
+// they took
+func readFractionalSeconds(r io.Reader, metadata *ColumnMetadata) (uint64, int, error) {
+	fsp, err := metadata.FractionalSecondsPrecision()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	packSize := fractionalSecondsPackSize(int(fsp))
 
 	if packSize == 0 {
-		return 0, nil
+		return 0, 0, nil
 	}
 
 	b, err := ReadBytes(r, packSize)
 	if err != nil {
-		return 0, err
+		return 0, 0, err
 	}
 
-	// pad byte array so that it is 4 bytes in total
-	buf := bytes.NewBuffer(padBytesBigEndian(b, 4 - packSize))
-
-	var fractionalSeconds int32
-	binary.Read(buf, binary.BigEndian, &fractionalSeconds)
+	return bigEndianUint(b), packSize, nil
+}
 
-	return fractionalSeconds, nil
+func readMicroseconds(r io.Reader, metadata *ColumnMetadata) (int64, error) {
+	fraction, packSize, err := readFractionalSeconds(r, metadata)
+	return int64(fraction) * fractionalSecondsScale[packSize], err
 }
 
-func removeFractionalSeconds(milliseconds uint) uint {
-	return milliseconds - (milliseconds % 1000)
+func bigEndianUint(b []byte) uint64 {
+	var v uint64
+
+	for _, c := range b {
+		v = v << 8 | uint64(c)
+	}
+
+	return v
 }
 
 /*
 TIME V2
 =======
 
-3 bytes
+3 bytes + fractional seconds
 Big Endian
 
-1 bit   = sign
+The integer part is stored plus TIMEF_INT_OFS, so that negative times
+sort before positive ones:
+
+1 bit   = sign (1 = positive)
 1 bit   = reserved
 10 bits = hour
 6 bits  = minute
 6 bits  = second
 
+A negative time with fsp 1 to 4 has its integer part rounded down and
+the fraction stored as the (positive) amount to add back, e.g. -1.5
+seconds is stored as -2 and 0.5. With fsp 5 or 6 all 6 bytes together
+are the packed time (integer part << 24 + microseconds) plus
+TIMEF_OFS, which comes to the same thing.
+
 */
 
-func ReadTimeV2(r io.Reader) (time.Duration, error) {
-	set, err := ReadBitset(r, 24)
+func ReadTimeV2(r io.Reader, metadata *ColumnMetadata) (time.Duration, error) {
+	b, err := ReadBytes(r, 3)
 	if err != nil {
 		return time.Duration(0), err
 	}
 
-	var sign   int
-	var hour   uint16
-	var minute uint8
-	var second uint8
+	intPart := int64(bigEndianUint(b)) - TIMEF_INT_OFS
 
-	if set.Bit(0) {
-		sign = 1
-	} else {
-		sign = -1
-	}
-
-	err = binary.Read(bytes.NewBuffer(expandBitsetToBytesBigEndian(set.Splice(2, 12), 10)), binary.BigEndian, &hour)
+	fraction, packSize, err := readFractionalSeconds(r, metadata)
 	if err != nil {
 		return time.Duration(0), err
 	}
 
-	err = binary.Read(bytes.NewBuffer(expandBitsetToBytesBigEndian(set.Splice(12, 18), 6)), binary.BigEndian, &minute)
-	if err != nil {
-		return time.Duration(0), err
+	frac := int64(fraction)
+
+	if packSize < 3 && intPart < 0 && frac != 0 {
+		intPart++
+		frac -= 1 << uint(8 * packSize)
 	}
 
-	err = binary.Read(bytes.NewBuffer(expandBitsetToBytesBigEndian(set.Splice(18, 24), 6)), binary.BigEndian, &second)
-	if err != nil {
-		return time.Duration(0), err
+	packed := intPart << 24 + frac * fractionalSecondsScale[packSize]
+
+	sign := time.Duration(1)
+	if packed < 0 {
+		sign = -1
+		packed = -packed
 	}
 
-	return time.Duration(sign) * ((time.Hour * time.Duration(hour)) + (time.Minute * time.Duration(minute)) + (time.Second * time.Duration(second))), nil
+	hms := packed >> 24
+	hour := time.Duration(hms >> 12 & 0x3ff)
+	minute := time.Duration(hms >> 6 & 0x3f)
+	second := time.Duration(hms & 0x3f)
+	microsecond := time.Duration(packed & 0xffffff)
+
+	return sign * (time.Hour * hour + time.Minute * minute + time.Second * second + time.Microsecond * microsecond), nil
 }
 
 /*
 TIMESTAMP V2
 ============
 
-4 bytes + fsp bytes
+4 bytes + fractional seconds
 Big Endian
 
+seconds since the epoch, 0 for 0000-00-00 00:00:00
+
 */
 
+// Zero timestamps give the zero time.Time
 func ReadTimestampV2(r io.Reader, metadata *ColumnMetadata) (time.Time, error) {
-	millisecond, err := ReadUint32(r)
+	seconds, err := ReadBytes(r, 4)
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	fractionalSeconds, err := readFractionalSeconds(r, metadata)
+	microseconds, err := readMicroseconds(r, metadata)
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	return time.Unix(int64(removeFractionalSeconds(uint(millisecond))), int64(fractionalSeconds)), nil
+	if bigEndianUint(seconds) == 0 && microseconds == 0 {
+		return time.Time{}, nil
+	}
+
+	return time.Unix(int64(bigEndianUint(seconds)), microseconds * 1000).UTC(), nil
 }
 
 /*
 DATETIME V2
 ===========
 
-5 bytes
+5 bytes + fractional seconds
 Big Endian
 
-1 bit   = sign
+1 bit   = sign (always 1, as the value is stored plus DATETIMEF_INT_OFS)
 17 bits = year * 13 + month
 5 bits  = day
 5 bits  = hour
 6 bits  = minute
 6 bits  = second
 
-NOTE: We completely ignore the sign for this type
-
 */
 
+// 0000-00-00 00:00:00 gives the zero time.Time, and other dates with a
+// zero month or day an error
 func ReadDatetimeV2(r io.Reader, metadata *ColumnMetadata) (time.Time, error) {
-	var yearMonth uint32
-	var day       uint8
-	var hour      uint8
-	var minute    uint8
-	var second    uint8
-
-	set, err := ReadBitset(r, 48)
+	d, err := readDatetimeV2Parts(r, metadata)
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	yearMonthBytes := expandBitsetToBytesBigEndian(set.Splice(1, 18), 17) // 3 bytes
+	return d.Time()
+}
 
-	// pad to 4 bytes
-	err = binary.Read(bytes.NewBuffer(padBytesBigEndian(yearMonthBytes, 1)), binary.BigEndian, &yearMonth)
+func readDatetimeV2Parts(r io.Reader, metadata *ColumnMetadata) (MysqlDatetime, error) {
+	b, err := ReadBytes(r, 5)
 	if err != nil {
-		return time.Time{}, err
+		return MysqlDatetime{}, err
 	}
 
-	err = binary.Read(bytes.NewBuffer(expandBitsetToBytesBigEndian(set.Splice(18, 23), 5)), binary.BigEndian, &day)
+	microseconds, err := readMicroseconds(r, metadata)
 	if err != nil {
+		return MysqlDatetime{}, err
+	}
+
+	intPart := int64(bigEndianUint(b)) - DATETIMEF_INT_OFS
+	if intPart < 0 {
+		intPart = -intPart
+	}
+
+	ymd := intPart >> 17
+	yearMonth := ymd >> 5
+	hms := intPart & (1 << 17 - 1)
+
+	return MysqlDatetime{
+		Year:        int(yearMonth / 13),
+		Month:       int(yearMonth % 13),
+		Day:         int(ymd & 31),
+		Hour:        int(hms >> 12),
+		Minute:      int(hms >> 6 & 0x3f),
+		Second:      int(hms & 0x3f),
+		Microsecond: int(microseconds),
+	}, nil
+}
+
+/*
+TIMESTAMP V1
+============
+
+Written by servers before 5.6.4, and by later ones for columns created
+before then.
+
+4 bytes
+Little Endian
+
+seconds since the epoch, 0 for 0000-00-00 00:00:00
+
+*/
+
+// Zero timestamps give the zero time.Time
+func ReadTimestampV1(r io.Reader) (time.Time, error) {
+	seconds, err := ReadUint32(r)
+	if err != nil || seconds == 0 {
 		return time.Time{}, err
 	}
 
-	err = binary.Read(bytes.NewBuffer(expandBitsetToBytesBigEndian(set.Splice(23, 28), 5)), binary.BigEndian, &hour)
+	return time.Unix(int64(seconds), 0).UTC(), nil
+}
+
+/*
+DATE
+====
+
+3 bytes
+Little Endian
+
+15 bits = year
+4 bits  = month
+5 bits  = day
+
+(from most to least significant)
+
+*/
+
+// 0000-00-00 gives the zero time.Time, and other dates with a zero month
+// or day an error
+func ReadDate(r io.Reader) (time.Time, error) {
+	d, err := readDateParts(r)
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	err = binary.Read(bytes.NewBuffer(expandBitsetToBytesBigEndian(set.Splice(28, 34), 5)), binary.BigEndian, &minute)
+	return d.Time()
+}
+
+func readDateParts(r io.Reader) (MysqlDatetime, error) {
+	v, err := ReadUintN(r, 3)
 	if err != nil {
-		return time.Time{}, err
+		return MysqlDatetime{}, err
 	}
 
-	err = binary.Read(bytes.NewBuffer(expandBitsetToBytesBigEndian(set.Splice(34, 40), 5)), binary.BigEndian, &second)
+	return MysqlDatetime{Year: int(v >> 9), Month: int(v >> 5 & 15), Day: int(v & 31)}, nil
+}
+
+/*
+TIME V1
+=======
+
+3 bytes
+Little Endian
+
+signed integer HHMMSS, e.g. -8385959 for -838:59:59
+
+*/
+
+func ReadTimeV1(r io.Reader) (time.Duration, error) {
+	v, err := ReadUintN(r, 3)
+	if err != nil {
+		return time.Duration(0), err
+	}
+
+	hhmmss := int64(v)
+	if v & 0x800000 != 0 {
+		hhmmss -= 1 << 24
+	}
+
+	sign := time.Duration(1)
+	if hhmmss < 0 {
+		sign = -1
+		hhmmss = -hhmmss
+	}
+
+	hour := time.Duration(hhmmss / 10000)
+	minute := time.Duration(hhmmss / 100 % 100)
+	second := time.Duration(hhmmss % 100)
+
+	return sign * (time.Hour * hour + time.Minute * minute + time.Second * second), nil
+}
+
+/*
+DATETIME V1
+===========
+
+8 bytes
+Little Endian
+
+unsigned integer YYYYMMDDHHMMSS, e.g. 20140521153012
+
+*/
+
+// 0000-00-00 00:00:00 gives the zero time.Time, and other dates with a
+// zero month or day an error
+func ReadDatetimeV1(r io.Reader) (time.Time, error) {
+	d, err := readDatetimeV1Parts(r)
 	if err != nil {
 		return time.Time{}, err
 	}
 
-	return time.Date(int(yearMonth / 13), time.Month(yearMonth % 13 - 1), int(day), int(hour), int(minute), int(second), 0, time.UTC), nil
+	return d.Time()
+}
+
+func readDatetimeV1Parts(r io.Reader) (MysqlDatetime, error) {
+	v, err := ReadUint64(r)
+	if err != nil {
+		return MysqlDatetime{}, err
+	}
+
+	date, clock := v / 1000000, v % 1000000
+
+	return MysqlDatetime{
+		Year:   int(date / 10000),
+		Month:  int(date / 100 % 100),
+		Day:    int(date % 100),
+		Hour:   int(clock / 10000),
+		Minute: int(clock / 100 % 100),
+		Second: int(clock % 100),
+	}, nil
 }
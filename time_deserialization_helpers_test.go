@@ -0,0 +1,136 @@
+package binlog
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func fspTestMetadata(fsp byte) *ColumnMetadata {
+	return &ColumnMetadata{data: []byte{fsp}, metaType: TIME_V2_METADATA}
+}
+
+func TestReadTimeV2(t *testing.T) {
+	hms := func(h, m, s, us int) time.Duration {
+		return time.Duration(h) * time.Hour + time.Duration(m) * time.Minute + time.Duration(s) * time.Second + time.Duration(us) * time.Microsecond
+	}
+
+	tests := []struct {
+		fsp   byte
+		data  []byte
+		value time.Duration
+	}{
+		{0, []byte{0x80, 0x00, 0x00}, 0},
+		{0, []byte{0x80, 0xc8, 0xb8}, hms(12, 34, 56, 0)},
+		{0, []byte{0x7f, 0xff, 0xff}, -hms(0, 0, 1, 0)},
+		{0, []byte{0x4b, 0x91, 0x05}, -hms(838, 59, 59, 0)},
+		{0, []byte{0xb4, 0x6e, 0xfb}, hms(838, 59, 59, 0)},
+		{1, []byte{0x7f, 0xff, 0xfe, 0xce}, -hms(0, 0, 1, 500000)},
+		{1, []byte{0x80, 0x00, 0x01, 0x32}, hms(0, 0, 1, 500000)},
+		{2, []byte{0x7f, 0xef, 0x7c, 0xd3}, -hms(1, 2, 3, 450000)},
+		{4, []byte{0x7f, 0xff, 0xff, 0xff, 0xff}, -hms(0, 0, 0, 100)},
+		{4, []byte{0x80, 0xa0, 0x00, 0x04, 0xd2}, hms(10, 0, 0, 123400)},
+		{5, []byte{0x7f, 0xff, 0xff, 0xff, 0xff, 0xf6}, -hms(0, 0, 0, 10)},
+		{6, []byte{0x7f, 0xff, 0xfe, 0xff, 0xff, 0xff}, -hms(0, 0, 1, 1)},
+		{6, []byte{0x81, 0x7e, 0xfb, 0x0f, 0x42, 0x3f}, hms(23, 59, 59, 999999)},
+		{6, []byte{0x4b, 0x91, 0x05, 0x00, 0x00, 0x00}, -hms(838, 59, 59, 0)},
+	}
+
+	for _, test := range tests {
+		r := bytes.NewReader(test.data)
+		v, err := ReadTimeV2(r, fspTestMetadata(test.fsp))
+		checkErr(t, err)
+		assert.Equal(t, test.value, v, "fsp %v %x", test.fsp, test.data)
+		assert.Equal(t, 0, r.Len())
+	}
+}
+
+func TestReadDatetimeV2(t *testing.T) {
+	tests := []struct {
+		fsp   byte
+		data  []byte
+		value time.Time
+	}{
+		{0, []byte{0x99, 0x92, 0xea, 0xf7, 0x8c}, time.Date(2014, 5, 21, 15, 30, 12, 0, time.UTC)},
+		{2, []byte{0x99, 0x94, 0xbf, 0x7e, 0xfb, 0x63}, time.Date(2014, 12, 31, 23, 59, 59, 990000000, time.UTC)},
+		{3, []byte{0x8c, 0xb2, 0x42, 0x00, 0x00, 0x00, 0x05}, time.Date(1000, 1, 1, 0, 0, 0, 500000, time.UTC)},
+		{5, []byte{0x99, 0x91, 0xc2, 0x00, 0x00, 0x01, 0xe2, 0x3a}, time.Date(2014, 1, 1, 0, 0, 0, 123450000, time.UTC)},
+		{6, []byte{0xfe, 0xf3, 0xff, 0x7e, 0xfb, 0x0f, 0x42, 0x3f}, time.Date(9999, 12, 31, 23, 59, 59, 999999000, time.UTC)},
+		{0, []byte{0x80, 0x00, 0x00, 0x00, 0x00}, time.Time{}},
+	}
+
+	for _, test := range tests {
+		r := bytes.NewReader(test.data)
+		v, err := ReadDatetimeV2(r, fspTestMetadata(test.fsp))
+		checkErr(t, err)
+		assert.Equal(t, test.value, v, "fsp %v %x", test.fsp, test.data)
+		assert.Equal(t, 0, r.Len())
+	}
+}
+
+func TestZeroInDatetimeV2(t *testing.T) {
+	tests := []struct {
+		fsp   byte
+		data  []byte
+		value MysqlDatetime
+	}{
+		{0, []byte{0x99, 0x91, 0x80, 0x00, 0x00}, MysqlDatetime{Year: 2014}},
+		{3, []byte{0x99, 0x92, 0xc0, 0xc7, 0x80, 0x09, 0xc4}, MysqlDatetime{Year: 2014, Month: 5, Hour: 12, Minute: 30, Microsecond: 250000}},
+	}
+
+	for _, test := range tests {
+		_, err := ReadDatetimeV2(bytes.NewReader(test.data), fspTestMetadata(test.fsp))
+		assert.Error(t, err, "%x", test.data)
+
+		tableMap := &TableMapEvent{ColumnTypes: []byte{MYSQL_TYPE_DATETIME_V2}, Metadata: []*ColumnMetadata{fspTestMetadata(test.fsp)}}
+
+		r := bytes.NewReader(test.data)
+		cell, err := DeserializeRowImageCell(r, tableMap, 0)
+		checkErr(t, err)
+		assert.Equal(t, ZeroInDateRowImageCell{mysqlType: MYSQL_TYPE_DATETIME_V2, value: test.value}, cell)
+		assert.Equal(t, 0, r.Len())
+	}
+
+	assert.Equal(t, "2014-05-00 12:30:00.250000", tests[1].value.String())
+}
+
+func TestReadTimestampV2(t *testing.T) {
+	tests := []struct {
+		fsp   byte
+		data  []byte
+		value time.Time
+	}{
+		{0, []byte{0x53, 0x72, 0x4e, 0x00}, time.Unix(1400000000, 0).UTC()},
+		{1, []byte{0x53, 0x72, 0x4e, 0x00, 0x0c}, time.Unix(1400000000, 120000000).UTC()},
+		{4, []byte{0x7f, 0xff, 0xff, 0xff, 0x27, 0x0f}, time.Unix(2147483647, 999900000).UTC()},
+		{6, []byte{0x00, 0x00, 0x00, 0x01, 0x0f, 0x42, 0x3f}, time.Unix(1, 999999000).UTC()},
+		{3, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, time.Time{}},
+	}
+
+	for _, test := range tests {
+		r := bytes.NewReader(test.data)
+		v, err := ReadTimestampV2(r, fspTestMetadata(test.fsp))
+		checkErr(t, err)
+		assert.Equal(t, test.value, v, "fsp %v %x", test.fsp, test.data)
+		assert.Equal(t, 0, r.Len())
+	}
+
+	_, err := ReadTimestampV2(bytes.NewReader([]byte{0x53, 0x72, 0x4e, 0x00, 0x0c}), fspTestMetadata(3))
+	assert.Error(t, err)
+}
+
+func TestFractionalSecondsPrecision(t *testing.T) {
+	for fsp := byte(0); fsp <= 6; fsp++ {
+		v, err := fspTestMetadata(fsp).FractionalSecondsPrecision()
+		checkErr(t, err)
+		assert.Equal(t, fsp, v)
+	}
+
+	_, err := fspTestMetadata(7).FractionalSecondsPrecision()
+	assert.Error(t, err)
+
+	_, err = (&ColumnMetadata{data: []byte{3, 0}, metaType: TIME_V2_METADATA}).FractionalSecondsPrecision()
+	assert.Error(t, err)
+}
@@ -0,0 +1,126 @@
+package binlog
+
+import (
+	"io"
+	"iter"
+	"strings"
+)
+
+// A group of events that were committed together
+type Transaction struct {
+	StartPosition   int64  // offset of the BEGIN event
+	EndPosition     int64  // offset just past the XID_EVENT or COMMIT
+	CommitTimestamp uint32 // header timestamp of the XID_EVENT or COMMIT
+	Xid             uint64 // 0 when the transaction ended with a COMMIT query
+	Gtid            *GtidEvent // nil unless gtid_mode is ON
+	Events          []*Event
+}
+
+/*
+TRANSACTION GROUPING
+====================
+
+A transaction in the log looks like:
+
+QUERY_EVENT       "BEGIN"
+TABLE_MAP_EVENT   \
+WRITE_ROWS_EVENT   |  any number of row
+QUERY_EVENT        |  and statement events
+...               /
+XID_EVENT         (or a QUERY_EVENT "COMMIT" for non-transactional tables)
+
+Transaction.Events holds the rows and query events in between, in the
+order they were written, without the BEGIN and the XID/COMMIT. A
+"ROLLBACK" query ends the transaction the same way a COMMIT does, since
+it is only logged when changes to non-transactional tables could not be
+rolled back.
+
+Statements that are logged without a BEGIN (DDL, which commits
+implicitly) are returned as a transaction of their own holding just
+that query. With gtid_mode ON, the GTID_EVENT right before the BEGIN
+(or the DDL) is kept on the transaction. Everything else outside of a
+transaction is skipped.
+
+*/
+
+func isTransactionEvent(event *Event) bool {
+	switch event.data.(type) {
+	case *RowsEvent, *UpdateRowsEvent, *QueryEvent:
+		return true
+	}
+
+	return false
+}
+
+func queryIs(event *Event, statement string) bool {
+	query, ok := event.data.(*QueryEvent)
+	return ok && strings.EqualFold(strings.TrimSpace(query.Query), statement)
+}
+
+// Reads events from next until a whole transaction has been seen
+func readTransaction(next func() (*Event, error)) (*Transaction, error) {
+	var t *Transaction
+	var gtid *GtidEvent
+
+	for {
+		event, err := next()
+
+		if err != nil {
+			if err == io.EOF && t != nil {
+				return nil, &ErrIncompleteTransaction{StartPosition: t.StartPosition}
+			}
+
+			return nil, err
+		}
+
+		end := event.offset + int64(event.header.Length)
+
+		if t == nil {
+			if queryIs(event, "BEGIN") {
+				t = &Transaction{StartPosition: event.offset, Gtid: gtid}
+			} else if _, ok := event.data.(*QueryEvent); ok {
+				return &Transaction{
+					StartPosition:   event.offset,
+					EndPosition:     end,
+					CommitTimestamp: event.header.Timestamp,
+					Gtid:            gtid,
+					Events:          []*Event{event},
+				}, nil
+			} else if next, ok := event.data.(*GtidEvent); ok {
+				gtid = next
+			} else if _, ok := event.data.(*AnonymousGtidEvent); ok {
+				gtid = nil
+			}
+
+			continue
+		}
+
+		if xid, ok := event.data.(*XidEvent); ok {
+			t.Xid = xid.Xid
+		} else if !queryIs(event, "COMMIT") && !queryIs(event, "ROLLBACK") {
+			if isTransactionEvent(event) {
+				t.Events = append(t.Events, event)
+			}
+
+			continue
+		}
+
+		t.EndPosition = end
+		t.CommitTimestamp = event.header.Timestamp
+
+		return t, nil
+	}
+}
+
+// Reads events up to the end of the next transaction. Returns io.EOF once
+// the log has been read to the end, or *ErrIncompleteTransaction if it
+// ends part way through a transaction.
+func (b *Binlog) NextTransaction() (*Transaction, error) {
+	return readTransaction(b.NextEvent)
+}
+
+// Returns an iterator over the remaining transactions, which works the
+// same way as Events. Check Err once the loop is done.
+func (b *Binlog) Transactions() iter.Seq[*Transaction] {
+	return iterate(b.NextTransaction, &b.err)
+}
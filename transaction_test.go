@@ -0,0 +1,76 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func xidTestEvent(xid uint64) testEvent {
+	payload := new(bytes.Buffer)
+	binary.Write(payload, binary.LittleEndian, xid)
+
+	return testEvent{XID_EVENT, payload.Bytes()}
+}
+
+func TestTransactions(t *testing.T) {
+	data := makeChecksummedTestBinlog(
+		queryTestEvent("shop", "BEGIN", nil),
+		queryTestEvent("shop", "INSERT INTO t VALUES (1)", nil),
+		queryTestEvent("shop", "INSERT INTO t VALUES (2)", nil),
+		xidTestEvent(77),
+		testEvent{STOP_EVENT, nil},
+		queryTestEvent("shop", "CREATE TABLE u (id INT)", nil),
+		queryTestEvent("shop", "BEGIN", nil),
+		queryTestEvent("shop", "UPDATE m SET a = 1", nil),
+		queryTestEvent("shop", "COMMIT", nil),
+	)
+
+	b := openTestBinlog(t, data)
+
+	transactions := []*Transaction{}
+	for transaction := range b.Transactions() {
+		transactions = append(transactions, transaction)
+	}
+
+	checkErr(t, b.Err())
+
+	if !assert.Len(t, transactions, 3) {
+		return
+	}
+
+	first := transactions[0]
+	assert.Equal(t, uint64(77), first.Xid)
+	assert.Equal(t, uint32(1400000000), first.CommitTimestamp)
+	assert.Len(t, first.Events, 2)
+	assert.Equal(t, "INSERT INTO t VALUES (2)", first.Events[1].Data().(*QueryEvent).Query)
+	assert.True(t, first.StartPosition < first.Events[0].Offset())
+
+	ddl := transactions[1]
+	assert.Equal(t, uint64(0), ddl.Xid)
+	assert.Len(t, ddl.Events, 1)
+	assert.Equal(t, ddl.StartPosition, ddl.Events[0].Offset())
+	assert.True(t, ddl.EndPosition > first.EndPosition)
+
+	last := transactions[2]
+	assert.Equal(t, uint64(0), last.Xid)
+	assert.Len(t, last.Events, 1)
+	assert.Equal(t, int64(len(data)), last.EndPosition)
+}
+
+func TestIncompleteTransaction(t *testing.T) {
+	b := openTestBinlog(t, makeTestBinlog(
+		queryTestEvent("shop", "BEGIN", nil),
+		queryTestEvent("shop", "INSERT INTO t VALUES (1)", nil),
+	))
+
+	_, err := b.NextTransaction()
+
+	var incomplete *ErrIncompleteTransaction
+	if assert.True(t, errors.As(err, &incomplete), "expected incomplete transaction, got %v", err) {
+		assert.Equal(t, int64(120), incomplete.StartPosition)
+	}
+}
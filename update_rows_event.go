@@ -0,0 +1,94 @@
+package binlog
+
+import (
+	"io"
+	"reflect"
+)
+
+// The row as it was before an update and as it is after it. Columns that
+// weren't logged in an image (see binlog_row_image) are nil in it.
+type RowChange struct {
+	Before RowImage
+	After  RowImage
+}
+
+// Returns the indexes of the columns present in both images whose
+// values differ
+func (c RowChange) ChangedColumns() []int {
+	changed := []int{}
+
+	for i := 0; i < len(c.Before) && i < len(c.After); i++ {
+		if c.Before[i] == nil || c.After[i] == nil {
+			continue
+		}
+
+		if !reflect.DeepEqual(c.Before[i], c.After[i]) {
+			changed = append(changed, i)
+		}
+	}
+
+	return changed
+}
+
+type UpdateRowsEvent struct {
+	RowsEventHeader
+	BeforeUsedSet Bitset // columns_before_image
+	AfterUsedSet  Bitset // columns_after_image
+	Rows          []RowChange
+}
+
+type UpdateRowsEventDeserializer struct {}
+
+// See ROWS EVENT DATA in rows_event.go
+func (d *UpdateRowsEventDeserializer) Deserialize(b *Binlog, reader io.ReadSeeker, header *EventHeader) (EventData, error) {
+	e := new(UpdateRowsEvent)
+
+	tableMap, err := readRowsEventHeader(b, reader, header, &e.RowsEventHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	e.BeforeUsedSet, err = ReadBitset(reader, int(e.NumberOfColumns))
+	if err != nil {
+		return nil, err
+	}
+
+	e.AfterUsedSet, err = ReadBitset(reader, int(e.NumberOfColumns))
+	if err != nil {
+		return nil, err
+	}
+
+	e.Rows = []RowChange{}
+
+	err = readRows(reader, e.TableId, func() error {
+		var change RowChange
+
+		change.Before, err = readRowImage(reader, tableMap, e.BeforeUsedSet, e.NumberOfColumns)
+		if err != nil {
+			return err
+		}
+
+		change.After, err = readRowImage(reader, tableMap, e.AfterUsedSet, e.NumberOfColumns)
+		if err != nil {
+			return err
+		}
+
+		e.Rows = append(e.Rows, change)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	b.logger.Log(LOG_LEVEL_DEBUG, "Update rows event",
+		Field("version", e.Version),
+		Field("table_id", e.TableId),
+		Field("flags", e.Flags),
+		Field("columns", e.NumberOfColumns),
+		Field("before_used_set", e.BeforeUsedSet),
+		Field("after_used_set", e.AfterUsedSet),
+		Field("rows", len(e.Rows)),
+	)
+
+	return e, nil
+}
@@ -0,0 +1,266 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// WKB geometry types
+const (
+	WKB_POINT              uint32 = 1 + iota
+	WKB_LINESTRING
+	WKB_POLYGON
+	WKB_MULTIPOINT
+	WKB_MULTILINESTRING
+	WKB_MULTIPOLYGON
+	WKB_GEOMETRYCOLLECTION
+)
+
+/*
+GEOMETRY VALUES
+===============
+
+Stored like a BLOB, with a length of the metadata's pack size, and then
+
+4 bytes = SRID, little endian
+rest    = the geometry in Well-Known Binary (WKB)
+
+WKB
+===
+
+Each geometry, including those nested in a multi-* or a collection, is
+
+1 byte  = byte order: 0 big endian, 1 little endian
+4 bytes = geometry type (WKB_*)
+
+followed by, in that byte order:
+
+POINT:              8 bytes x, 8 bytes y (IEEE 754 doubles)
+LINESTRING:         4 bytes point count, then the points' x and y
+POLYGON:            4 bytes ring count, then each ring as a LINESTRING body
+MULTI*, COLLECTION: 4 bytes geometry count, then each a full WKB geometry
+
+*/
+
+// Renders a WKB geometry as Well-Known Text, the way MySQL's ST_AsText
+// does: POINT(1 2), LINESTRING(0 0,1 1), POLYGON((0 0,1 0,0 1,0 0)) ...
+func WKBToWKT(wkb []byte) (string, error) {
+	p := &wkbParser{data: wkb}
+
+	wkt, err := p.geometry()
+	if err != nil {
+		return "", err
+	}
+
+	if p.offset != len(wkb) {
+		return "", fmt.Errorf("%v bytes left over after the geometry", len(wkb) - p.offset)
+	}
+
+	return wkt, nil
+}
+
+type wkbParser struct {
+	data   []byte
+	offset int
+	order  binary.ByteOrder
+}
+
+func (p *wkbParser) next(n int) ([]byte, error) {
+	if p.offset + n > len(p.data) {
+		return nil, fmt.Errorf("WKB is cut short at byte %v", p.offset)
+	}
+
+	b := p.data[p.offset:p.offset + n]
+	p.offset += n
+
+	return b, nil
+}
+
+func (p *wkbParser) uint32() (uint32, error) {
+	b, err := p.next(4)
+	if err != nil {
+		return 0, err
+	}
+
+	return p.order.Uint32(b), nil
+}
+
+// Reads a count, making sure the data could hold that many items of at
+// least minimumSize bytes each so a corrupt count fails fast
+func (p *wkbParser) count(minimumSize int) (int, error) {
+	n, err := p.uint32()
+	if err != nil {
+		return 0, err
+	}
+
+	if uint64(n) * uint64(minimumSize) > uint64(len(p.data) - p.offset) {
+		return 0, fmt.Errorf("WKB count %v is more than the data holds", n)
+	}
+
+	return int(n), nil
+}
+
+func (p *wkbParser) point() (string, error) {
+	b, err := p.next(16)
+	if err != nil {
+		return "", err
+	}
+
+	x := math.Float64frombits(p.order.Uint64(b[:8]))
+	y := math.Float64frombits(p.order.Uint64(b[8:]))
+
+	return formatWKTNumber(x) + " " + formatWKTNumber(y), nil
+}
+
+// Reads a point count and the points, as "x y,x y"
+func (p *wkbParser) points() (string, error) {
+	n, err := p.count(16)
+	if err != nil {
+		return "", err
+	}
+
+	points := make([]string, n)
+
+	for i := range points {
+		if points[i], err = p.point(); err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(points, ","), nil
+}
+
+func (p *wkbParser) rings() (string, error) {
+	n, err := p.count(4)
+	if err != nil {
+		return "", err
+	}
+
+	rings := make([]string, n)
+
+	for i := range rings {
+		points, err := p.points()
+		if err != nil {
+			return "", err
+		}
+
+		rings[i] = "(" + points + ")"
+	}
+
+	return strings.Join(rings, ","), nil
+}
+
+// Reads a byte order and type, returning the type
+func (p *wkbParser) header() (uint32, error) {
+	b, err := p.next(1)
+	if err != nil {
+		return 0, err
+	}
+
+	switch b[0] {
+	case 0:
+		p.order = binary.BigEndian
+	case 1:
+		p.order = binary.LittleEndian
+	default:
+		return 0, fmt.Errorf("invalid WKB byte order %v", b[0])
+	}
+
+	return p.uint32()
+}
+
+func (p *wkbParser) geometry() (string, error) {
+	geometryType, err := p.header()
+	if err != nil {
+		return "", err
+	}
+
+	switch geometryType {
+	case WKB_POINT:
+		point, err := p.point()
+		return "POINT(" + point + ")", err
+
+	case WKB_LINESTRING:
+		points, err := p.points()
+		return "LINESTRING(" + points + ")", err
+
+	case WKB_POLYGON:
+		rings, err := p.rings()
+		return "POLYGON(" + rings + ")", err
+
+	case WKB_MULTIPOINT, WKB_MULTILINESTRING, WKB_MULTIPOLYGON:
+		return p.multi(geometryType)
+
+	case WKB_GEOMETRYCOLLECTION:
+		n, err := p.count(5)
+		if err != nil {
+			return "", err
+		}
+
+		geometries := make([]string, n)
+
+		for i := range geometries {
+			if geometries[i], err = p.geometry(); err != nil {
+				return "", err
+			}
+		}
+
+		return "GEOMETRYCOLLECTION(" + strings.Join(geometries, ",") + ")", nil
+	}
+
+	return "", fmt.Errorf("unknown WKB geometry type %v", geometryType)
+}
+
+// Multi geometries hold full WKB geometries of the matching single type,
+// which are written without their own type names
+func (p *wkbParser) multi(geometryType uint32) (string, error) {
+	n, err := p.count(5)
+	if err != nil {
+		return "", err
+	}
+
+	name := map[uint32]string{
+		WKB_MULTIPOINT:      "MULTIPOINT",
+		WKB_MULTILINESTRING: "MULTILINESTRING",
+		WKB_MULTIPOLYGON:    "MULTIPOLYGON",
+	}[geometryType]
+
+	parts := make([]string, n)
+
+	for i := range parts {
+		partType, err := p.header()
+		if err != nil {
+			return "", err
+		}
+
+		if partType != geometryType - 3 {
+			return "", fmt.Errorf("%v holds a geometry of type %v", name, partType)
+		}
+
+		var part string
+
+		switch partType {
+		case WKB_POINT:
+			part, err = p.point()
+		case WKB_LINESTRING:
+			part, err = p.points()
+		case WKB_POLYGON:
+			part, err = p.rings()
+		}
+
+		if err != nil {
+			return "", err
+		}
+
+		parts[i] = "(" + part + ")"
+	}
+
+	return name + "(" + strings.Join(parts, ",") + ")", nil
+}
+
+func formatWKTNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,150 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Builds a WKB geometry header in the given byte order
+func wkbTestHeader(order binary.ByteOrder, geometryType uint32) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+
+	if order == binary.LittleEndian {
+		buf.WriteByte(1)
+	} else {
+		buf.WriteByte(0)
+	}
+
+	binary.Write(buf, order, geometryType)
+	return buf
+}
+
+func wkbTestPoint(order binary.ByteOrder, x, y float64) []byte {
+	buf := wkbTestHeader(order, WKB_POINT)
+	binary.Write(buf, order, []float64{x, y})
+	return buf.Bytes()
+}
+
+func wkbTestLineString(order binary.ByteOrder, coordinates ...float64) []byte {
+	buf := wkbTestHeader(order, WKB_LINESTRING)
+	binary.Write(buf, order, uint32(len(coordinates) / 2))
+	binary.Write(buf, order, coordinates)
+	return buf.Bytes()
+}
+
+func wkbTestPolygon(order binary.ByteOrder, rings ...[]float64) []byte {
+	buf := wkbTestHeader(order, WKB_POLYGON)
+	binary.Write(buf, order, uint32(len(rings)))
+
+	for _, ring := range rings {
+		binary.Write(buf, order, uint32(len(ring) / 2))
+		binary.Write(buf, order, ring)
+	}
+
+	return buf.Bytes()
+}
+
+func wkbTestCollection(order binary.ByteOrder, geometryType uint32, geometries ...[]byte) []byte {
+	buf := wkbTestHeader(order, geometryType)
+	binary.Write(buf, order, uint32(len(geometries)))
+
+	for _, g := range geometries {
+		buf.Write(g)
+	}
+
+	return buf.Bytes()
+}
+
+func TestWKBToWKT(t *testing.T) {
+	le, be := binary.LittleEndian, binary.BigEndian
+	square := []float64{0, 0, 10, 0, 10, 10, 0, 10, 0, 0}
+	hole := []float64{2, 2, 4, 2, 4, 4, 2, 2}
+
+	tests := []struct {
+		wkb []byte
+		wkt string
+	}{
+		{wkbTestPoint(le, 1, 2), "POINT(1 2)"},
+		{wkbTestPoint(be, -1.5, 0.25), "POINT(-1.5 0.25)"},
+		{wkbTestPoint(le, 1e21, math.SmallestNonzeroFloat64), "POINT(1e+21 5e-324)"},
+		{wkbTestLineString(le, 0, 0, 1, 1, 2, 0), "LINESTRING(0 0,1 1,2 0)"},
+		{wkbTestPolygon(be, square, hole), "POLYGON((0 0,10 0,10 10,0 10,0 0),(2 2,4 2,4 4,2 2))"},
+		{
+			wkbTestCollection(le, WKB_MULTIPOINT, wkbTestPoint(le, 1, 2), wkbTestPoint(be, 3, 4)),
+			"MULTIPOINT((1 2),(3 4))",
+		},
+		{
+			wkbTestCollection(be, WKB_MULTILINESTRING, wkbTestLineString(le, 0, 0, 1, 1), wkbTestLineString(be, 2, 2, 3, 3)),
+			"MULTILINESTRING((0 0,1 1),(2 2,3 3))",
+		},
+		{
+			wkbTestCollection(le, WKB_MULTIPOLYGON, wkbTestPolygon(le, square), wkbTestPolygon(le, square, hole)),
+			"MULTIPOLYGON(((0 0,10 0,10 10,0 10,0 0)),((0 0,10 0,10 10,0 10,0 0),(2 2,4 2,4 4,2 2)))",
+		},
+		{
+			wkbTestCollection(le, WKB_GEOMETRYCOLLECTION,
+				wkbTestPoint(be, 1, 2),
+				wkbTestCollection(le, WKB_GEOMETRYCOLLECTION, wkbTestLineString(le, 0, 0, 1, 1)),
+				wkbTestCollection(be, WKB_MULTIPOINT, wkbTestPoint(le, 5, 6)),
+			),
+			"GEOMETRYCOLLECTION(POINT(1 2),GEOMETRYCOLLECTION(LINESTRING(0 0,1 1)),MULTIPOINT((5 6)))",
+		},
+		{wkbTestCollection(le, WKB_GEOMETRYCOLLECTION), "GEOMETRYCOLLECTION()"},
+	}
+
+	for _, test := range tests {
+		wkt, err := WKBToWKT(test.wkb)
+		checkErr(t, err)
+		assert.Equal(t, test.wkt, wkt)
+	}
+}
+
+func TestBadWKB(t *testing.T) {
+	le := binary.LittleEndian
+	point := wkbTestPoint(le, 1, 2)
+
+	tests := [][]byte{
+		{},
+		point[:len(point) - 1],
+		append(append([]byte{}, point...), 0),
+		append([]byte{2}, point[1:]...),
+		wkbTestHeader(le, 8).Bytes(),
+		// A count far larger than the data
+		append(wkbTestHeader(le, WKB_LINESTRING).Bytes(), 0xff, 0xff, 0xff, 0xff),
+		wkbTestCollection(le, WKB_MULTIPOINT, wkbTestLineString(le, 0, 0, 1, 1)),
+	}
+
+	for _, wkb := range tests {
+		_, err := WKBToWKT(wkb)
+		assert.Error(t, err, "%x", wkb)
+	}
+}
+
+func TestGeometryColumn(t *testing.T) {
+	tableMap := &TableMapEvent{
+		ColumnTypes: []byte{MYSQL_TYPE_GEOMETRY},
+		Metadata:    []*ColumnMetadata{{data: []byte{4}, metaType: PACK_SIZE_METADATA}},
+	}
+
+	wkb := wkbTestPoint(binary.LittleEndian, 1, 2)
+	value := append([]byte{0xe6, 0x10, 0, 0}, wkb...) // SRID 4326
+
+	r := bytes.NewReader(append([]byte{byte(len(value)), 0, 0, 0}, value...))
+	cell, err := DeserializeRowImageCell(r, tableMap, 0)
+	checkErr(t, err)
+
+	if geometry, ok := cell.(GeometryRowImageCell); assert.True(t, ok) {
+		assert.Equal(t, uint32(4326), geometry.SRID())
+		assert.Equal(t, wkb, geometry.WKB())
+		assert.Equal(t, "POINT(1 2)", geometry.String())
+	}
+
+	assert.Equal(t, 0, r.Len())
+
+	_, err = DeserializeRowImageCell(bytes.NewReader([]byte{3, 0, 0, 0, 1, 2, 3}), tableMap, 0)
+	assert.Error(t, err)
+}
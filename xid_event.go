@@ -0,0 +1,34 @@
+package binlog
+
+import (
+	"io"
+)
+
+// Written when a transaction touching transactional tables commits
+type XidEvent struct {
+	Xid uint64
+}
+
+type XidEventDeserializer struct {}
+
+/*
+XID EVENT DATA
+==============
+
+No post-header.
+
+Variable:
+8 bytes = xid
+
+*/
+
+func (d *XidEventDeserializer) Deserialize(b *Binlog, reader io.ReadSeeker, header *EventHeader) (EventData, error) {
+	xid, err := ReadUint64(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	b.logger.Log(LOG_LEVEL_DEBUG, "Xid event", Field("xid", xid))
+
+	return &XidEvent{Xid: xid}, nil
+}